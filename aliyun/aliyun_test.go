@@ -1,11 +1,19 @@
 package aliyun_test
 
 import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	aliyunoss "github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/jinzhu/configor"
+	"github.com/qor/oss"
 	"github.com/qor/oss/aliyun"
 	"github.com/qor/oss/tests"
 )
@@ -57,3 +65,46 @@ func TestAll(t *testing.T) {
 		tests.TestAll(cli, t)
 	}
 }
+
+func TestChecksum(t *testing.T) {
+	content := []byte("sample content")
+	crc := oss.NewCRC64()
+	crc.Write(content)
+
+	for _, corrupt := range []bool{false, true} {
+		var contentMD5 string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body := append([]byte{}, content...)
+			w.Header().Set("X-Oss-Hash-Crc64ecma", fmt.Sprint(crc.Sum64()))
+			if req.Method == "PUT" {
+				contentMD5 = req.Header.Get("Content-MD5")
+				return
+			}
+			if corrupt {
+				body[0] ^= 1
+			}
+			w.Write(body)
+		}))
+
+		client := aliyun.New(&aliyun.Config{AccessID: "access_id", AccessKey: "access_key", Bucket: "bucket", Endpoint: server.URL})
+		object, err := client.Put("/sample.txt", bytes.NewReader(content))
+		if err != nil {
+			t.Fatalf("No error should happen when put object, but got %v", err)
+		}
+		if sum := md5.Sum(content); object.Checksum == nil || !bytes.Equal(object.Checksum.MD5, sum[:]) || contentMD5 != base64.StdEncoding.EncodeToString(sum[:]) {
+			t.Errorf("Put object should be sent with Content-MD5 and have MD5 checksum %x, but got %q, %+v", sum, contentMD5, object.Checksum)
+		}
+
+		stream, err := client.GetStream("/sample.txt")
+		if err != nil {
+			t.Fatalf("No error should happen when get object, but got %v", err)
+		}
+		_, err = ioutil.ReadAll(stream)
+		stream.Close()
+
+		if corrupt != errors.Is(err, oss.ErrChecksumMismatch) {
+			t.Errorf("Reading corrupted object should fail with oss.ErrChecksumMismatch, corrupted: %v, got %v", corrupt, err)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,471 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/qor/oss"
+	"github.com/qor/oss/filesystem"
+)
+
+var (
+	_ oss.ContextStorage = (*Cache)(nil)
+	_ oss.Stater         = (*Cache)(nil)
+	_ oss.PageLister     = (*Cache)(nil)
+	_ oss.OptionsPutter  = (*Cache)(nil)
+	_ oss.Copier         = (*Cache)(nil)
+	_ oss.Mover          = (*Cache)(nil)
+	_ oss.RangeGetter    = (*Cache)(nil)
+	_ oss.BatchDeleter   = (*Cache)(nil)
+)
+
+// Config cache config
+type Config struct {
+	// Dir local directory to keep cached objects in, it should be dedicated to the cache, as files left by
+	// previous processes are not tracked and cached files are removed when evicted
+	Dir string
+	// MaxSize max total size of cached objects in bytes, least recently read objects are evicted once it is exceeded, default is 1GB
+	MaxSize int64
+	// MaxAge cached objects are served without revalidation for MaxAge after downloaded or revalidated,
+	// 0 means revalidating ETag and LastModified with the storage on every read
+	MaxAge time.Duration
+}
+
+// Stats cache statistics
+type Stats struct {
+	// Hits number of reads served from local disk, including revalidated ones
+	Hits int64
+	// Misses number of reads downloaded from the storage
+	Misses int64
+	// Revalidations number of cached objects checked with the storage
+	Revalidations int64
+	// Evictions number of cached objects evicted to keep total size under MaxSize
+	Evictions int64
+	// Objects number of cached objects
+	Objects int
+	// Size total size of cached objects in bytes
+	Size int64
+}
+
+// Cache read-through local disk cache of storage, objects are downloaded to local disk on first read and served from it
+// until they are changed in the storage. Put, Delete, Copy and Move through the cache invalidate cached objects,
+// other operations go to the storage directly. Reads are served from the storage if the local disk fails
+type Cache struct {
+	storage oss.StorageInterface
+	local   *filesystem.FileSystem
+	config  Config
+
+	mutex   sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	stats   Stats
+}
+
+type entry struct {
+	key          string
+	size         int64
+	etag         string
+	lastModified *time.Time
+	validatedAt  time.Time
+}
+
+// New initialize read-through cache of storage, the storage should implement oss.Stater to revalidate cached objects without downloading them
+func New(storage oss.StorageInterface, config Config) (*Cache, error) {
+	if config.Dir == "" {
+		return nil, errors.New("cache: no directory given")
+	}
+	if _, ok := storage.(oss.Stater); !ok {
+		return nil, errors.New("cache: storage doesn't implement oss.Stater to revalidate cached objects")
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = 1 << 30
+	}
+
+	if err := os.MkdirAll(config.Dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	return &Cache{
+		storage: storage,
+		local:   filesystem.New(config.Dir),
+		config:  config,
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+	}, nil
+}
+
+// key normalize path to cache key, keys always begin with "/"
+func key(p string) string {
+	return path.Clean("/" + p)
+}
+
+// name get path of cached file of key in the local storage, files are named by hash of their keys,
+// so keys like "a" and "a/b" don't conflict as file and directory
+func name(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "/" + hex.EncodeToString(sum[:])
+}
+
+// localError error of local disk, reads fall back to the storage on it
+type localError struct {
+	err error
+}
+
+func (e *localError) Error() string {
+	return "cache: " + e.err.Error()
+}
+
+func (e *localError) Unwrap() error {
+	return e.err
+}
+
+func isLocal(err error) bool {
+	var local *localError
+	return errors.As(err, &local)
+}
+
+// storageReader record read errors of the storage, to tell them from errors of local disk
+type storageReader struct {
+	io.Reader
+	err error
+}
+
+func (reader *storageReader) Read(p []byte) (int, error) {
+	n, err := reader.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		reader.err = err
+	}
+	return n, err
+}
+
+// Stats get cache statistics
+func (cache *Cache) Stats() Stats {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	stats := cache.stats
+	stats.Objects = cache.lru.Len()
+	return stats
+}
+
+// Invalidate remove cached object of path, it is called by operations changing the object through the cache,
+// call it when the object is changed in the storage directly to avoid waiting for revalidation
+func (cache *Cache) Invalidate(path string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if elem, ok := cache.entries[key(path)]; ok {
+		cache.remove(elem)
+	}
+}
+
+// remove entry of elem and its file, must be called with lock held
+func (cache *Cache) remove(elem *list.Element) {
+	e := cache.lru.Remove(elem).(*entry)
+	delete(cache.entries, e.key)
+	cache.stats.Size -= e.size
+	cache.local.Delete(name(e.key))
+}
+
+// lookup get cached entry of path, it is revalidated with the storage if it is older than MaxAge.
+// returns nil if path isn't cached or the object is changed
+func (cache *Cache) lookup(path string) (*entry, error) {
+	cache.mutex.Lock()
+	elem, ok := cache.entries[key(path)]
+	if !ok {
+		cache.mutex.Unlock()
+		return nil, nil
+	}
+	cached := elem.Value.(*entry)
+	validatedAt := cached.validatedAt
+	cache.mutex.Unlock()
+
+	if cache.config.MaxAge > 0 && time.Since(validatedAt) < cache.config.MaxAge {
+		return cached, nil
+	}
+
+	object, err := oss.Stat(cache.storage, path)
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.stats.Revalidations++
+
+	// entry might be replaced or invalidated while revalidating
+	if elem, ok = cache.entries[cached.key]; !ok || elem.Value != cached {
+		return nil, err
+	}
+
+	if err != nil || !unchanged(cached, object) {
+		cache.remove(elem)
+		return nil, err
+	}
+
+	cached.validatedAt = time.Now()
+	return cached, nil
+}
+
+// unchanged check cached entry matches object in the storage with ETag, or LastModified if the storage doesn't return ETags
+func unchanged(cached *entry, object *oss.Object) bool {
+	if cached.etag != "" || object.ETag != "" {
+		return cached.etag == object.ETag
+	}
+	return cached.lastModified != nil && object.LastModified != nil && cached.lastModified.Equal(*object.LastModified)
+}
+
+// open open cached object of path, downloading it from the storage if it isn't cached, errors of local disk are *localError
+func (cache *Cache) open(ctx context.Context, path string) (*os.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	cached, err := cache.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached != nil {
+		if file, err := cache.local.Get(name(cached.key)); err == nil {
+			cache.touch(cached.key, true)
+			return file, nil
+		}
+		// cached file removed by others
+		cache.Invalidate(path)
+	}
+
+	cache.touch(key(path), false)
+	return cache.download(ctx, path)
+}
+
+// touch mark key as recently read, and count hit or miss
+func (cache *Cache) touch(key string, hit bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if hit {
+		cache.stats.Hits++
+		if elem, ok := cache.entries[key]; ok {
+			cache.lru.MoveToFront(elem)
+		}
+	} else {
+		cache.stats.Misses++
+	}
+}
+
+// download download object of path from the storage into the local storage, which writes a temporary file and renames
+// it once completed, so concurrent reads never see partial content. The object is stat again after downloading, and it
+// isn't cached if it is changed meanwhile, as the downloaded content might not match the ETag
+func (cache *Cache) download(ctx context.Context, path string) (*os.File, error) {
+	object, err := oss.Stat(cache.storage, path)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := oss.WithContext(cache.storage).GetStreamCtx(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var (
+		k      = key(path)
+		reader = &storageReader{Reader: oss.ContextReader(ctx, stream)}
+	)
+
+	stored, err := cache.local.Put(name(k), reader)
+	if reader.err != nil {
+		return nil, reader.err
+	} else if err != nil {
+		return nil, &localError{err}
+	}
+
+	file, err := cache.local.Get(name(k))
+	if err != nil {
+		return nil, &localError{err}
+	}
+
+	downloaded := &entry{key: k, size: stored.Size, etag: object.ETag, lastModified: object.LastModified, validatedAt: time.Now()}
+	if current, err := oss.Stat(cache.storage, path); err != nil || !unchanged(downloaded, current) {
+		// serve the downloaded content, but don't cache it
+		cache.local.Delete(name(k))
+		return file, nil
+	}
+
+	cache.add(downloaded)
+	return file, nil
+}
+
+// add add entry as the most recently read one, evicting least recently read entries to keep total size under MaxSize
+func (cache *Cache) add(e *entry) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if elem, ok := cache.entries[e.key]; ok {
+		// the file is already replaced by the new download
+		cache.stats.Size -= elem.Value.(*entry).size
+		cache.lru.Remove(elem)
+	}
+
+	cache.entries[e.key] = cache.lru.PushFront(e)
+	cache.stats.Size += e.size
+
+	for cache.stats.Size > cache.config.MaxSize && cache.lru.Len() > 1 {
+		cache.remove(cache.lru.Back())
+		cache.stats.Evictions++
+	}
+}
+
+// own make object's methods go through the cache
+func (cache *Cache) own(object *oss.Object) *oss.Object {
+	if object != nil {
+		object.StorageInterface = cache
+	}
+	return object
+}
+
+// Get receive file with given path, the file is the cached one, it must be closed and must not be changed
+func (cache *Cache) Get(path string) (*os.File, error) {
+	return cache.GetCtx(context.Background(), path)
+}
+
+// GetCtx receive file with given path, the file is the cached one, it must be closed and must not be changed
+func (cache *Cache) GetCtx(ctx context.Context, path string) (*os.File, error) {
+	file, err := cache.open(ctx, path)
+	if isLocal(err) {
+		return oss.WithContext(cache.storage).GetCtx(ctx, path)
+	}
+	return file, err
+}
+
+// GetStream get file as stream, it is read from the cached file
+func (cache *Cache) GetStream(path string) (io.ReadCloser, error) {
+	return cache.GetStreamCtx(context.Background(), path)
+}
+
+// GetStreamCtx get file as stream, it is read from the cached file, reading fails once ctx is done
+func (cache *Cache) GetStreamCtx(ctx context.Context, path string) (io.ReadCloser, error) {
+	file, err := cache.open(ctx, path)
+	if isLocal(err) {
+		return oss.WithContext(cache.storage).GetStreamCtx(ctx, path)
+	} else if err != nil {
+		return nil, err
+	}
+	return oss.ContextReadCloser(ctx, file), nil
+}
+
+// GetRange get length bytes of object starting at offset, the whole object is downloaded to cache on miss
+func (cache *Cache) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	file, err := cache.open(context.Background(), path)
+	if isLocal(err) {
+		return oss.GetRange(cache.storage, path, offset, length)
+	} else if err != nil {
+		return nil, err
+	}
+	return oss.LimitRange(file, offset, length)
+}
+
+// Put store a reader into given path, and invalidate cached object
+func (cache *Cache) Put(path string, reader io.Reader) (*oss.Object, error) {
+	return cache.PutCtx(context.Background(), path, reader)
+}
+
+// PutCtx store a reader into given path, and invalidate cached object
+func (cache *Cache) PutCtx(ctx context.Context, path string, reader io.Reader) (*oss.Object, error) {
+	defer cache.Invalidate(path)
+	object, err := oss.WithContext(cache.storage).PutCtx(ctx, path, reader)
+	return cache.own(object), err
+}
+
+// PutWithOptions store a reader into given path with options, and invalidate cached object
+func (cache *Cache) PutWithOptions(path string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	defer cache.Invalidate(path)
+	object, err := oss.PutWithOptions(cache.storage, path, reader, options)
+	return cache.own(object), err
+}
+
+// Stat get object's metadata from the storage
+func (cache *Cache) Stat(path string) (*oss.Object, error) {
+	object, err := oss.Stat(cache.storage, path)
+	return cache.own(object), err
+}
+
+// Delete delete object, and invalidate cached object
+func (cache *Cache) Delete(path string) error {
+	return cache.DeleteCtx(context.Background(), path)
+}
+
+// DeleteCtx delete object, and invalidate cached object
+func (cache *Cache) DeleteCtx(ctx context.Context, path string) error {
+	defer cache.Invalidate(path)
+	return oss.WithContext(cache.storage).DeleteCtx(ctx, path)
+}
+
+// DeleteMany delete objects at paths, and invalidate cached objects
+func (cache *Cache) DeleteMany(paths []string) map[string]error {
+	defer func() {
+		for _, path := range paths {
+			cache.Invalidate(path)
+		}
+	}()
+	return oss.DeleteMany(cache.storage, paths)
+}
+
+// Copy copy object from src to dst, and invalidate cached dst
+func (cache *Cache) Copy(src, dst string) error {
+	defer cache.Invalidate(dst)
+	return oss.Copy(cache.storage, src, dst)
+}
+
+// Move move object from src to dst, and invalidate cached src and dst
+func (cache *Cache) Move(src, dst string) error {
+	defer cache.Invalidate(src)
+	defer cache.Invalidate(dst)
+	return oss.Move(cache.storage, src, dst)
+}
+
+// List list all objects under current path from the storage
+func (cache *Cache) List(path string) ([]*oss.Object, error) {
+	return cache.ListCtx(context.Background(), path)
+}
+
+// ListCtx list all objects under current path from the storage
+func (cache *Cache) ListCtx(ctx context.Context, path string) ([]*oss.Object, error) {
+	objects, err := oss.WithContext(cache.storage).ListCtx(ctx, path)
+	for _, object := range objects {
+		cache.own(object)
+	}
+	return objects, err
+}
+
+// ListPage list a page of objects from the storage
+func (cache *Cache) ListPage(options oss.ListOptions) (*oss.ListResult, error) {
+	result, err := oss.ListPage(cache.storage, options)
+	if result != nil {
+		for _, object := range result.Objects {
+			cache.own(object)
+		}
+	}
+	return result, err
+}
+
+// GetEndpoint get endpoint of the storage
+func (cache *Cache) GetEndpoint() string {
+	return cache.storage.GetEndpoint()
+}
+
+// GetURL get URL of the storage
+func (cache *Cache) GetURL(path string) (string, error) {
+	return cache.storage.GetURL(path)
+}
+
+// GetURLCtx get URL of the storage
+func (cache *Cache) GetURLCtx(ctx context.Context, path string) (string, error) {
+	return oss.WithContext(cache.storage).GetURLCtx(ctx, path)
+}
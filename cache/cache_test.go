@@ -0,0 +1,243 @@
+package cache
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qor/oss"
+	"github.com/qor/oss/memory"
+	"github.com/qor/oss/tests"
+)
+
+// newCache initialize cache in a temporary directory, which should be removed by caller
+func newCache(t *testing.T, storage oss.StorageInterface, config Config) *Cache {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatalf("No error should happen when create cache directory, but got %v", err)
+	}
+
+	config.Dir = dir
+	cache, err := New(storage, config)
+	if err != nil {
+		t.Fatalf("No error should happen when initialize cache, but got %v", err)
+	}
+	return cache
+}
+
+func read(t *testing.T, storage oss.StorageInterface, path string) string {
+	file, err := storage.Get(path)
+	if err != nil {
+		t.Fatalf("No error should happen when get %v, but got %v", path, err)
+	}
+	defer file.Close()
+
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		t.Fatalf("No error should happen when read %v, but got %v", path, err)
+	}
+	return string(content)
+}
+
+func TestAll(t *testing.T) {
+	cache := newCache(t, memory.New(), Config{})
+	defer os.RemoveAll(cache.config.Dir)
+
+	tests.TestAll(cache, t)
+}
+
+func TestHitAndMiss(t *testing.T) {
+	storage := memory.New()
+	storage.Put("/sample.txt", strings.NewReader("sample"))
+	cache := newCache(t, storage, Config{})
+	defer os.RemoveAll(cache.config.Dir)
+
+	for i := 0; i < 3; i++ {
+		if content := read(t, cache, "/sample.txt"); content != "sample" {
+			t.Errorf("Cached file should contain sample, but got %v", content)
+		}
+	}
+
+	if stats := cache.Stats(); stats.Hits != 2 || stats.Misses != 1 || stats.Revalidations != 2 || stats.Objects != 1 || stats.Size != 6 {
+		t.Errorf("Stats should count 2 hits, 1 miss and 2 revalidations of 1 object, but got %+v", stats)
+	}
+
+	// changed in the storage directly
+	storage.Put("/sample.txt", strings.NewReader("changed"))
+	if content := read(t, cache, "/sample.txt"); content != "changed" {
+		t.Errorf("Changed object should be downloaded again, but got %v", content)
+	}
+	if stats := cache.Stats(); stats.Misses != 2 || stats.Size != 7 {
+		t.Errorf("Changed object should be a miss replacing cached one, but got %+v", stats)
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	storage := memory.New()
+	storage.Put("/sample.txt", strings.NewReader("sample"))
+	cache := newCache(t, storage, Config{MaxAge: time.Hour})
+	defer os.RemoveAll(cache.config.Dir)
+
+	read(t, cache, "/sample.txt")
+	storage.Put("/sample.txt", strings.NewReader("changed"))
+
+	if content := read(t, cache, "/sample.txt"); content != "sample" {
+		t.Errorf("Cached object should be served without revalidation within MaxAge, but got %v", content)
+	}
+	if stats := cache.Stats(); stats.Revalidations != 0 || stats.Hits != 1 {
+		t.Errorf("Cached object should not be revalidated within MaxAge, but got %+v", stats)
+	}
+
+	cache.Invalidate("/sample.txt")
+	if content := read(t, cache, "/sample.txt"); content != "changed" {
+		t.Errorf("Invalidated object should be downloaded again, but got %v", content)
+	}
+}
+
+func TestInvalidation(t *testing.T) {
+	storage := memory.New()
+	storage.Put("/sample.txt", strings.NewReader("sample"))
+	cache := newCache(t, storage, Config{MaxAge: time.Hour})
+	defer os.RemoveAll(cache.config.Dir)
+
+	read(t, cache, "/sample.txt")
+	if _, err := cache.Put("/sample.txt", strings.NewReader("changed")); err != nil {
+		t.Fatalf("No error should happen when put sample file, but got %v", err)
+	}
+	if content := read(t, cache, "/sample.txt"); content != "changed" {
+		t.Errorf("Put through cache should invalidate cached object, but got %v", content)
+	}
+
+	if err := cache.Delete("/sample.txt"); err != nil {
+		t.Fatalf("No error should happen when delete sample file, but got %v", err)
+	}
+	if _, err := cache.Get("/sample.txt"); err == nil {
+		t.Errorf("Delete through cache should invalidate cached object")
+	}
+	if stats := cache.Stats(); stats.Objects != 0 || stats.Size != 0 {
+		t.Errorf("Deleted object should be removed from cache, but got %+v", stats)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	storage := memory.New()
+	for _, path := range []string{"/a.txt", "/b.txt", "/c.txt"} {
+		storage.Put(path, strings.NewReader("0123456789"))
+	}
+	cache := newCache(t, storage, Config{MaxSize: 25})
+	defer os.RemoveAll(cache.config.Dir)
+
+	read(t, cache, "/a.txt")
+	read(t, cache, "/b.txt")
+	read(t, cache, "/a.txt")
+	read(t, cache, "/c.txt")
+
+	if stats := cache.Stats(); stats.Evictions != 1 || stats.Objects != 2 || stats.Size != 20 {
+		t.Errorf("Least recently read object should be evicted, but got %+v", stats)
+	}
+
+	if _, err := cache.local.Stat(name(key("/b.txt"))); !errors.Is(err, oss.ErrNotExist) {
+		t.Errorf("Evicted file should be removed, but got %v", err)
+	}
+
+	read(t, cache, "/a.txt")
+	if stats := cache.Stats(); stats.Hits != 2 {
+		t.Errorf("Recently read object should be kept, but got %+v", stats)
+	}
+}
+
+func TestNestedKeys(t *testing.T) {
+	storage := memory.New()
+	storage.Put("/a", strings.NewReader("a"))
+	storage.Put("/a/b", strings.NewReader("a/b"))
+	storage.Put("/c/d", strings.NewReader("c/d"))
+	storage.Put("/c", strings.NewReader("c"))
+	cache := newCache(t, storage, Config{})
+	defer os.RemoveAll(cache.config.Dir)
+
+	for _, path := range []string{"/a", "/a/b", "/c/d", "/c", "/a", "/c/d"} {
+		if content := read(t, cache, path); content != strings.TrimPrefix(path, "/") {
+			t.Errorf("Cached %v should be %v, but got %v", path, strings.TrimPrefix(path, "/"), content)
+		}
+	}
+
+	if stats := cache.Stats(); stats.Objects != 4 || stats.Hits != 2 {
+		t.Errorf("Objects whose keys are prefixes of others should be cached, but got %+v", stats)
+	}
+}
+
+func TestLocalFailure(t *testing.T) {
+	storage := memory.New()
+	storage.Put("/sample.txt", strings.NewReader("sample"))
+	cache := newCache(t, storage, Config{})
+	defer os.RemoveAll(cache.config.Dir)
+
+	// cache directory replaced by a file
+	os.RemoveAll(cache.config.Dir)
+	ioutil.WriteFile(cache.config.Dir, []byte("file"), os.ModePerm)
+
+	if content := read(t, cache, "/sample.txt"); content != "sample" {
+		t.Errorf("Object should be got from the storage if cache directory fails, but got %v", content)
+	}
+
+	stream, err := cache.GetStream("/sample.txt")
+	if err != nil {
+		t.Fatalf("Object should be streamed from the storage if cache directory fails, but got %v", err)
+	}
+	defer stream.Close()
+	if content, _ := ioutil.ReadAll(stream); string(content) != "sample" {
+		t.Errorf("Object should be streamed from the storage if cache directory fails, but got %v", string(content))
+	}
+
+	if stream, err := oss.GetRange(cache, "/sample.txt", 1, 3); err != nil {
+		t.Errorf("Range should be got from the storage if cache directory fails, but got %v", err)
+	} else if content, _ := ioutil.ReadAll(stream); string(content) != "amp" {
+		t.Errorf("Range should be got from the storage if cache directory fails, but got %v", string(content))
+	}
+}
+
+func TestChangedWhileDownloading(t *testing.T) {
+	storage := memory.New()
+	storage.Put("/sample.txt", strings.NewReader("sample"))
+	cache := newCache(t, storage, Config{MaxAge: time.Hour})
+	defer os.RemoveAll(cache.config.Dir)
+
+	var changed bool
+	storage.Failure = func(op, path string) error {
+		if op == "get" && !changed {
+			changed = true
+			storage.Put(path, strings.NewReader("changed"))
+		}
+		return nil
+	}
+
+	if content := read(t, cache, "/sample.txt"); content != "changed" {
+		t.Errorf("Downloaded content should be served, but got %v", content)
+	}
+	if stats := cache.Stats(); stats.Objects != 0 || stats.Size != 0 {
+		t.Errorf("Object changed while downloading should not be cached, but got %+v", stats)
+	}
+
+	if content := read(t, cache, "/sample.txt"); content != "changed" {
+		t.Errorf("Changed object should be downloaded again, but got %v", content)
+	}
+	if stats := cache.Stats(); stats.Objects != 1 || stats.Misses != 2 {
+		t.Errorf("Unchanged object should be cached, but got %+v", stats)
+	}
+}
+
+func TestWithoutStater(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatalf("No error should happen when create cache directory, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	storage := struct{ oss.StorageInterface }{memory.New()}
+	if _, err := New(storage, Config{Dir: dir}); err == nil {
+		t.Errorf("Storage without oss.Stater should not be cached, as cached objects can't be revalidated")
+	}
+}
@@ -0,0 +1,132 @@
+package oss
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+	"io/ioutil"
+)
+
+// ErrChecksumMismatch returned at the end of an object's stream if the content read doesn't match the checksum
+// recorded by the storage provider, e.g. the object is corrupted in transit
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+var (
+	crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+	crc64Table  = crc64.MakeTable(crc64.ECMA)
+)
+
+// Checksum checksums of an object's content, computed while putting it
+type Checksum struct {
+	MD5    []byte
+	SHA256 []byte
+	CRC32C uint32
+}
+
+// ContentMD5 base64 encoded MD5, the value of Content-MD5 header
+func (checksum *Checksum) ContentMD5() string {
+	return base64.StdEncoding.EncodeToString(checksum.MD5)
+}
+
+// ChecksumReader computes checksums and size of content read through it
+type ChecksumReader struct {
+	reader io.Reader
+	md5    hash.Hash
+	sha256 hash.Hash
+	crc32c hash.Hash32
+	size   int64
+}
+
+// NewChecksumReader compute checksums of content read from reader
+func NewChecksumReader(reader io.Reader) *ChecksumReader {
+	if reader == nil {
+		reader = bytes.NewReader(nil)
+	}
+	return &ChecksumReader{reader: reader, md5: md5.New(), sha256: sha256.New(), crc32c: crc32.New(crc32cTable)}
+}
+
+func (reader *ChecksumReader) Read(p []byte) (int, error) {
+	n, err := reader.reader.Read(p)
+	if n > 0 {
+		reader.md5.Write(p[:n])
+		reader.sha256.Write(p[:n])
+		reader.crc32c.Write(p[:n])
+		reader.size += int64(n)
+	}
+	return n, err
+}
+
+// Size number of bytes read
+func (reader *ChecksumReader) Size() int64 {
+	return reader.size
+}
+
+// Checksum checksums of content read so far
+func (reader *ChecksumReader) Checksum() *Checksum {
+	return &Checksum{MD5: reader.md5.Sum(nil), SHA256: reader.sha256.Sum(nil), CRC32C: reader.crc32c.Sum32()}
+}
+
+// SeekerChecksum compute checksums of all content of seeker, and seek it back to the beginning, so the
+// checksums could be sent before the content
+func SeekerChecksum(seeker io.ReadSeeker) (*Checksum, error) {
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	reader := NewChecksumReader(seeker)
+	if _, err := io.Copy(ioutil.Discard, reader); err != nil {
+		return nil, err
+	}
+
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return reader.Checksum(), nil
+}
+
+// NewCRC64 CRC-64/ECMA hash used by Aliyun and Tencent COS for x-oss-hash-crc64ecma and x-cos-hash-crc64ecma headers
+func NewCRC64() hash.Hash64 {
+	return crc64.New(crc64Table)
+}
+
+// CRC64Sum encode CRC-64 value like hash.Hash64's Sum, to be compared with VerifyReader
+func CRC64Sum(value uint64) []byte {
+	sum := make([]byte, 8)
+	binary.BigEndian.PutUint64(sum, value)
+	return sum
+}
+
+// VerifyReader verify content read from stream with h, reading fails with an error matching ErrChecksumMismatch
+// at EOF if the sum isn't expected
+func VerifyReader(stream io.ReadCloser, h hash.Hash, expected []byte) io.ReadCloser {
+	return &verifyReader{stream: stream, hash: h, expected: expected}
+}
+
+type verifyReader struct {
+	stream   io.ReadCloser
+	hash     hash.Hash
+	expected []byte
+}
+
+func (reader *verifyReader) Read(p []byte) (int, error) {
+	n, err := reader.stream.Read(p)
+	reader.hash.Write(p[:n])
+	if err == io.EOF {
+		if sum := reader.hash.Sum(nil); !bytes.Equal(sum, reader.expected) {
+			return n, fmt.Errorf("oss: %w, expected %x but got %x", ErrChecksumMismatch, reader.expected, sum)
+		}
+	}
+	return n, err
+}
+
+func (reader *verifyReader) Close() error {
+	return reader.stream.Close()
+}
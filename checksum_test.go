@@ -0,0 +1,64 @@
+package oss_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/qor/oss"
+)
+
+func TestChecksumReader(t *testing.T) {
+	content := []byte("sample content")
+	reader := oss.NewChecksumReader(bytes.NewReader(content))
+	if _, err := ioutil.ReadAll(reader); err != nil {
+		t.Fatalf("No error should happen when read, but got %v", err)
+	}
+
+	var (
+		checksum  = reader.Checksum()
+		md5Sum    = md5.Sum(content)
+		sha256Sum = sha256.Sum256(content)
+	)
+	if !bytes.Equal(checksum.MD5, md5Sum[:]) || !bytes.Equal(checksum.SHA256, sha256Sum[:]) || checksum.CRC32C != crc32.Checksum(content, crc32.MakeTable(crc32.Castagnoli)) {
+		t.Errorf("Checksums of content are wrong, got %+v", checksum)
+	}
+	if reader.Size() != int64(len(content)) {
+		t.Errorf("Size should be %v, but got %v", len(content), reader.Size())
+	}
+
+	seeker := bytes.NewReader(content)
+	seeker.Seek(3, 0)
+	if sum, err := oss.SeekerChecksum(seeker); err != nil || sum.ContentMD5() != checksum.ContentMD5() {
+		t.Errorf("Checksums of seeker should be computed from the beginning, but got %+v, %v", sum, err)
+	}
+	if rest, _ := ioutil.ReadAll(seeker); !bytes.Equal(rest, content) {
+		t.Errorf("Seeker should be rewound to the beginning, but got %q", rest)
+	}
+}
+
+func TestVerifyReader(t *testing.T) {
+	sum := md5.Sum([]byte("sample"))
+
+	for content, mismatch := range map[string]bool{"sample": false, "simple": true, "sam": true} {
+		stream := oss.VerifyReader(ioutil.NopCloser(strings.NewReader(content)), md5.New(), sum[:])
+		read, err := ioutil.ReadAll(stream)
+		stream.Close()
+
+		if string(read) != content || mismatch != errors.Is(err, oss.ErrChecksumMismatch) {
+			t.Errorf("Reading %q should fail with oss.ErrChecksumMismatch: %v, but got %q, %v", content, mismatch, read, err)
+		}
+	}
+
+	crc := oss.NewCRC64()
+	crc.Write([]byte("sample"))
+	stream := oss.VerifyReader(ioutil.NopCloser(strings.NewReader("sample")), oss.NewCRC64(), oss.CRC64Sum(crc.Sum64()))
+	if _, err := ioutil.ReadAll(stream); err != nil {
+		t.Errorf("CRC-64 sum should be verified, but got %v", err)
+	}
+}
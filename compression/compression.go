@@ -0,0 +1,702 @@
+package compression
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/qor/oss"
+)
+
+var (
+	_ oss.ContextStorage = (*Storage)(nil)
+	_ oss.Stater         = (*Storage)(nil)
+	_ oss.PageLister     = (*Storage)(nil)
+	_ oss.OptionsPutter  = (*Storage)(nil)
+	_ oss.Copier         = (*Storage)(nil)
+	_ oss.Mover          = (*Storage)(nil)
+	_ oss.RangeGetter    = (*Storage)(nil)
+	_ oss.BatchDeleter   = (*Storage)(nil)
+)
+
+// Supported encodings
+const (
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+// MetadataKey metadata key recording encoding of compressed objects
+const MetadataKey = "compression"
+
+// suffixes key suffixes of compressed objects when metadata is unavailable
+var suffixes = map[string]string{Gzip: ".gz", Zstd: ".zst"}
+
+// whether the storage keeps metadata recording encoding
+const (
+	metadataUnknown int32 = iota
+	metadataKept
+	metadataDropped
+)
+
+// DefaultContentTypes content types compressed by default, types match if they begin with any of them
+var DefaultContentTypes = []string{
+	"text/",
+	"application/json",
+	"application/x-ndjson",
+	"application/xml",
+	"application/javascript",
+	"application/x-javascript",
+	"image/svg+xml",
+}
+
+// Config compression config
+type Config struct {
+	// Encoding Gzip or Zstd, default is Gzip
+	Encoding string
+	// ContentTypes only objects of these content types are compressed, types match if they begin with any of them, default is DefaultContentTypes
+	ContentTypes []string
+	// MinSize objects smaller than MinSize bytes are stored as is, default is 1024
+	MinSize int
+	// KeySuffix store compressed objects with encoding's suffix (.gz, .zst) appended to the key instead of recording encoding
+	// in metadata. Without it, compressed objects are checked with Stat after Put until the storage is found keeping metadata,
+	// and key suffix is used once the storage is found dropping metadata, like filesystem without Metadata, qiniu and ipfs
+	KeySuffix bool
+}
+
+// Storage transparent compression wrapper of storage. Objects are compressed on Put according to content type and size,
+// and decompressed on Get, GetStream and GetRange. Stat, List and ListPage return stored (compressed) sizes
+type Storage struct {
+	storage oss.StorageInterface
+	config  Config
+	// metadata whether the storage keeps metadata, accessed atomically
+	metadata int32
+}
+
+// New initialize compression wrapper of storage
+func New(storage oss.StorageInterface, config Config) (*Storage, error) {
+	if config.Encoding == "" {
+		config.Encoding = Gzip
+	}
+	if _, ok := suffixes[config.Encoding]; !ok {
+		return nil, fmt.Errorf("compression: unsupported encoding %q", config.Encoding)
+	}
+	if config.ContentTypes == nil {
+		config.ContentTypes = DefaultContentTypes
+	}
+	if config.MinSize <= 0 {
+		config.MinSize = 1024
+	}
+	return &Storage{storage: storage, config: config}, nil
+}
+
+// suffixed check compressed objects are stored with key suffix, with KeySuffix or if the storage drops metadata
+func (storage *Storage) suffixed() bool {
+	return storage.config.KeySuffix || atomic.LoadInt32(&storage.metadata) == metadataDropped
+}
+
+// own make object's methods go through the compression wrapper, and remove encoding's suffix from its path
+func (storage *Storage) own(object *oss.Object) *oss.Object {
+	if object != nil {
+		object.StorageInterface = storage
+		if storage.suffixed() {
+			for _, suffix := range suffixes {
+				if strings.HasSuffix(object.Path, suffix) {
+					object.Path = strings.TrimSuffix(object.Path, suffix)
+					object.Name = strings.TrimSuffix(object.Name, suffix)
+					break
+				}
+			}
+		}
+	}
+	return object
+}
+
+// compressible check objects of content type should be compressed
+func (storage *Storage) compressible(contentType string) bool {
+	for _, prefix := range storage.config.ContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve get stored path, encoding and stored object of path, encoding is empty if the object isn't compressed
+func (storage *Storage) resolve(path string) (string, string, *oss.Object, error) {
+	if storage.suffixed() {
+		for encoding, suffix := range suffixes {
+			if object, err := oss.Stat(storage.storage, path+suffix); err == nil {
+				return path + suffix, encoding, object, nil
+			} else if !errors.Is(err, oss.ErrNotExist) {
+				return "", "", nil, err
+			}
+		}
+
+		object, err := oss.Stat(storage.storage, path)
+		return path, "", object, err
+	}
+
+	object, err := oss.Stat(storage.storage, path)
+	if errors.Is(err, oss.ErrNotExist) && atomic.LoadInt32(&storage.metadata) == metadataUnknown {
+		// the object might be stored with key suffix as the storage drops metadata
+		for encoding, suffix := range suffixes {
+			if object, err := oss.Stat(storage.storage, path+suffix); err == nil {
+				atomic.StoreInt32(&storage.metadata, metadataDropped)
+				return path + suffix, encoding, object, nil
+			}
+		}
+	}
+	if err != nil {
+		return "", "", nil, err
+	}
+	return path, encodingOf(object), object, nil
+}
+
+// encodingOf get encoding recorded in object's metadata
+func encodingOf(object *oss.Object) string {
+	var encoding string
+	for name, value := range object.Metadata {
+		if strings.EqualFold(name, MetadataKey) {
+			encoding = value
+		}
+	}
+	return encoding
+}
+
+// checkMetadata check the storage keeps metadata recording encoding of compressed object stored at path, otherwise
+// the object is moved to path with key suffix, and objects are stored with key suffix from then on
+func (storage *Storage) checkMetadata(path string) error {
+	object, err := oss.Stat(storage.storage, path)
+	if err != nil {
+		return err
+	}
+
+	if encodingOf(object) != "" {
+		atomic.CompareAndSwapInt32(&storage.metadata, metadataUnknown, metadataKept)
+		return nil
+	}
+
+	atomic.StoreInt32(&storage.metadata, metadataDropped)
+	return oss.Move(storage.storage, path, path+suffixes[storage.config.Encoding])
+}
+
+// decompress wrap stream of encoding with decompressing reader
+func decompress(stream io.ReadCloser, encoding string) (io.ReadCloser, error) {
+	switch encoding {
+	case "":
+		return stream, nil
+	case Gzip:
+		reader, err := gzip.NewReader(stream)
+		if err != nil {
+			stream.Close()
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{reader, stream}, nil
+	case Zstd:
+		decoder, err := zstd.NewReader(stream)
+		if err != nil {
+			stream.Close()
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{decoder, closerFunc(func() error {
+			decoder.Close()
+			return stream.Close()
+		})}, nil
+	}
+
+	stream.Close()
+	return nil, fmt.Errorf("compression: unsupported encoding %q", encoding)
+}
+
+type closerFunc func() error
+
+func (fn closerFunc) Close() error {
+	return fn()
+}
+
+// Get receive decompressed file with given path, compressed objects are decompressed into a temporary file that is removed once closed
+func (storage *Storage) Get(path string) (*os.File, error) {
+	return storage.GetCtx(context.Background(), path)
+}
+
+// GetCtx receive decompressed file with given path, compressed objects are decompressed into a temporary file that is removed once closed
+func (storage *Storage) GetCtx(ctx context.Context, path string) (*os.File, error) {
+	stored, encoding, _, err := storage.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	if encoding == "" {
+		return oss.WithContext(storage.storage).GetCtx(ctx, stored)
+	}
+
+	stream, err := oss.WithContext(storage.storage).GetStreamCtx(ctx, stored)
+	if err != nil {
+		return nil, err
+	}
+	if stream, err = decompress(stream, encoding); err != nil {
+		return nil, oss.NewError("get", path, 0, err)
+	}
+	defer stream.Close()
+
+	file, err := ioutil.TempFile("", "compression*"+filepath.Ext(path))
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(file.Name())
+
+	if _, err = io.Copy(file, stream); err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, oss.NewError("get", path, 0, err)
+	}
+	return file, nil
+}
+
+// GetStream get decompressed file as stream
+func (storage *Storage) GetStream(path string) (io.ReadCloser, error) {
+	return storage.GetStreamCtx(context.Background(), path)
+}
+
+// GetStreamCtx get decompressed file as stream
+func (storage *Storage) GetStreamCtx(ctx context.Context, path string) (io.ReadCloser, error) {
+	stream, _, err := storage.GetStreamEncoded(ctx, path, "")
+	return stream, err
+}
+
+// GetStreamEncoded get file as stream, compressed objects are returned as is if their encoding is accepted by acceptEncoding,
+// an Accept-Encoding header value like "gzip, deflate, br", otherwise they are decompressed. Returns the stream's encoding,
+// empty if it isn't compressed, e.g.
+//
+//	stream, encoding, err := storage.GetStreamEncoded(req.Context(), path, req.Header.Get("Accept-Encoding"))
+//	if encoding != "" {
+//	  w.Header().Set("Content-Encoding", encoding)
+//	}
+func (storage *Storage) GetStreamEncoded(ctx context.Context, path, acceptEncoding string) (io.ReadCloser, string, error) {
+	stored, encoding, _, err := storage.resolve(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	stream, err := oss.WithContext(storage.storage).GetStreamCtx(ctx, stored)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if encoding == "" || accepts(acceptEncoding, encoding) {
+		return stream, encoding, nil
+	}
+
+	if stream, err = decompress(stream, encoding); err != nil {
+		return nil, "", oss.NewError("get", path, 0, err)
+	}
+	return stream, "", nil
+}
+
+// accepts check Accept-Encoding header value accepts encoding
+func accepts(acceptEncoding, encoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		if name := strings.TrimSpace(fields[0]); name != encoding && name != "*" {
+			continue
+		}
+
+		for _, param := range fields[1:] {
+			if param = strings.TrimSpace(param); strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// GetRange get length bytes of decompressed object starting at offset, compressed objects are decompressed from the beginning
+func (storage *Storage) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	stored, encoding, _, err := storage.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	if encoding == "" {
+		return oss.GetRange(storage.storage, stored, offset, length)
+	}
+
+	stream, err := storage.storage.GetStream(stored)
+	if err != nil {
+		return nil, err
+	}
+	if stream, err = decompress(stream, encoding); err != nil {
+		return nil, oss.NewError("get", path, 0, err)
+	}
+	return oss.LimitRange(stream, offset, length)
+}
+
+// Put store a reader into given path, compressing it if its content type and size match
+func (storage *Storage) Put(path string, reader io.Reader) (*oss.Object, error) {
+	return storage.PutCtx(context.Background(), path, reader)
+}
+
+// PutCtx store a reader into given path, compressing it if its content type and size match
+func (storage *Storage) PutCtx(ctx context.Context, path string, reader io.Reader) (*oss.Object, error) {
+	return storage.put(ctx, path, reader, &oss.PutOptions{})
+}
+
+// PutWithOptions store a reader into given path with options, compressing it if its content type and size match.
+// Readers with ContentEncoding are already encoded, they are stored as is with options. Compressed objects are stored
+// without ContentEncoding, their encoding is only recorded in metadata or key suffix, as HTTP clients decompress
+// responses with Content-Encoding transparently, the wrapper would then decompress plain content
+func (storage *Storage) PutWithOptions(path string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	if options == nil {
+		options = &oss.PutOptions{}
+	}
+	return storage.put(context.Background(), path, reader, options)
+}
+
+func (storage *Storage) put(ctx context.Context, path string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	if reader == nil {
+		reader = strings.NewReader("")
+	}
+
+	buffered := bufio.NewReaderSize(oss.ContextReader(ctx, reader), storage.config.MinSize+512)
+	peeked, err := buffered.Peek(storage.config.MinSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, oss.NewError("put", path, 0, err)
+	}
+
+	contentType := options.ContentType
+
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(path))
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(peeked)
+	}
+
+	suffixed := storage.suffixed()
+	if options.ContentEncoding != "" || len(peeked) < storage.config.MinSize || !storage.compressible(contentType) {
+		object, err := storage.putStored(path, buffered, options)
+		if err == nil && suffixed {
+			storage.removeVariants(path, "")
+		}
+		return storage.own(object), err
+	}
+
+	var (
+		counter       = oss.NewChecksumReader(buffered)
+		pipeReader, w = io.Pipe()
+		compressed    = *options
+		stored        = path
+	)
+
+	go func() {
+		w.CloseWithError(compress(w, counter, storage.config.Encoding))
+	}()
+	defer pipeReader.Close()
+
+	if compressed.ContentType == "" {
+		compressed.ContentType = contentType
+	}
+	if suffixed {
+		stored += suffixes[storage.config.Encoding]
+	} else {
+		compressed.Metadata = map[string]string{MetadataKey: storage.config.Encoding}
+		for name, value := range options.Metadata {
+			compressed.Metadata[name] = value
+		}
+	}
+
+	object, err := storage.putStored(stored, pipeReader, &compressed)
+	if err != nil {
+		return storage.own(object), err
+	}
+
+	if !suffixed && atomic.LoadInt32(&storage.metadata) != metadataKept {
+		if err = storage.checkMetadata(stored); err != nil {
+			return storage.own(object), oss.NewError("put", path, 0, err)
+		}
+		suffixed = storage.suffixed()
+	}
+
+	if suffixed {
+		storage.removeVariants(path, storage.config.Encoding)
+	}
+	object.Size = counter.Size()
+	object.Checksum = counter.Checksum()
+	return storage.own(object), nil
+}
+
+// putStored put reader to stored path with options, options are not sent if empty, so storages without OptionsPutter could detect content type
+func (storage *Storage) putStored(stored string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	if options.ContentType == "" && options.ContentDisposition == "" && options.CacheControl == "" &&
+		options.ContentEncoding == "" && options.ACL == "" && len(options.Metadata) == 0 {
+		return storage.storage.Put(stored, reader)
+	}
+	return oss.PutWithOptions(storage.storage, stored, reader, options)
+}
+
+// removeVariants remove stored objects of path with encodings other than keep in KeySuffix mode, "" means the uncompressed one
+func (storage *Storage) removeVariants(path, keep string) {
+	if keep != "" {
+		storage.storage.Delete(path)
+	}
+	for encoding, suffix := range suffixes {
+		if encoding != keep {
+			storage.storage.Delete(path + suffix)
+		}
+	}
+}
+
+func compress(w io.Writer, reader io.Reader, encoding string) error {
+	var writer io.WriteCloser
+	switch encoding {
+	case Zstd:
+		encoder, err := zstd.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		writer = encoder
+	default:
+		writer = gzip.NewWriter(w)
+	}
+
+	if _, err := io.Copy(writer, reader); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+// Stat get object's metadata, Size is the stored size
+func (storage *Storage) Stat(path string) (*oss.Object, error) {
+	_, _, object, err := storage.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return storage.own(object), nil
+}
+
+// Delete delete object
+func (storage *Storage) Delete(path string) error {
+	return storage.DeleteCtx(context.Background(), path)
+}
+
+// DeleteCtx delete object
+func (storage *Storage) DeleteCtx(ctx context.Context, path string) error {
+	if !storage.suffixed() {
+		err := oss.WithContext(storage.storage).DeleteCtx(ctx, path)
+		if errors.Is(err, oss.ErrNotExist) && atomic.LoadInt32(&storage.metadata) == metadataUnknown {
+			// the object might be stored with key suffix as the storage drops metadata
+			if stored, _, _, resolveErr := storage.resolve(path); resolveErr == nil && stored != path {
+				return storage.DeleteCtx(ctx, path)
+			}
+		}
+		return err
+	}
+
+	variants := storage.variants(path)
+	return variantsError(variants, oss.DeleteMany(storage.storage, variants))
+}
+
+// DeleteMany delete objects at paths
+func (storage *Storage) DeleteMany(paths []string) map[string]error {
+	if !storage.suffixed() {
+		errs := oss.DeleteMany(storage.storage, paths)
+		for path, err := range errs {
+			if errors.Is(err, oss.ErrNotExist) && atomic.LoadInt32(&storage.metadata) == metadataUnknown {
+				// the object might be stored with key suffix as the storage drops metadata
+				if err = storage.Delete(path); err == nil {
+					delete(errs, path)
+				} else {
+					errs[path] = err
+				}
+			}
+		}
+
+		if len(errs) == 0 {
+			return nil
+		}
+		return errs
+	}
+
+	var stored []string
+	for _, path := range paths {
+		stored = append(stored, storage.variants(path)...)
+	}
+
+	var (
+		failed = oss.DeleteMany(storage.storage, stored)
+		errs   = map[string]error{}
+	)
+	for _, path := range paths {
+		if err := variantsError(storage.variants(path), failed); err != nil {
+			errs[path] = err
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// variants stored paths of path in KeySuffix mode
+func (storage *Storage) variants(path string) []string {
+	variants := []string{path}
+	for _, suffix := range suffixes {
+		variants = append(variants, path+suffix)
+	}
+	return variants
+}
+
+// variantsError get error of deleting an object from errors of deleting its variants, it doesn't exist if none of variants exists
+func variantsError(variants []string, errs map[string]error) error {
+	var notExist error
+	for _, variant := range variants {
+		err := errs[variant]
+		if err == nil {
+			notExist = nil
+			break
+		}
+		if !errors.Is(err, oss.ErrNotExist) {
+			return err
+		}
+		notExist = err
+	}
+	return notExist
+}
+
+// Copy copy object from src to dst, compressed objects are copied as is
+func (storage *Storage) Copy(src, dst string) error {
+	stored, encoding, object, err := storage.resolve(src)
+	if err != nil {
+		return err
+	}
+
+	suffixed := storage.suffixed()
+	storedDst := dst
+	if encoding != "" && suffixed {
+		storedDst += suffixes[encoding]
+	}
+
+	if _, ok := storage.storage.(oss.Copier); ok || encoding == "" || suffixed {
+		err = oss.Copy(storage.storage, stored, storedDst)
+	} else {
+		// copy metadata recording encoding
+		err = storage.copyWithMetadata(stored, storedDst, object)
+	}
+
+	if err == nil && suffixed {
+		storage.removeVariants(dst, encoding)
+	}
+	return err
+}
+
+func (storage *Storage) copyWithMetadata(src, dst string, object *oss.Object) error {
+	stream, err := storage.storage.GetStream(src)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	_, err = oss.PutWithOptions(storage.storage, dst, stream, &oss.PutOptions{ContentType: object.ContentType, Metadata: object.Metadata})
+	return err
+}
+
+// Move move object from src to dst, compressed objects are moved as is
+func (storage *Storage) Move(src, dst string) error {
+	if _, ok := storage.storage.(oss.Mover); !ok {
+		if err := storage.Copy(src, dst); err != nil {
+			return err
+		}
+		return storage.Delete(src)
+	}
+
+	stored, encoding, _, err := storage.resolve(src)
+	if err != nil {
+		return err
+	}
+
+	suffixed := storage.suffixed()
+	storedDst := dst
+	if encoding != "" && suffixed {
+		storedDst += suffixes[encoding]
+	}
+
+	if err = oss.Move(storage.storage, stored, storedDst); err == nil && suffixed {
+		storage.removeVariants(dst, encoding)
+	}
+	return err
+}
+
+// List list all objects under current path, sizes are stored sizes
+func (storage *Storage) List(path string) ([]*oss.Object, error) {
+	return storage.ListCtx(context.Background(), path)
+}
+
+// ListCtx list all objects under current path, sizes are stored sizes
+func (storage *Storage) ListCtx(ctx context.Context, path string) ([]*oss.Object, error) {
+	objects, err := oss.WithContext(storage.storage).ListCtx(ctx, path)
+	for _, object := range objects {
+		storage.own(object)
+	}
+	return objects, err
+}
+
+// ListPage list a page of objects, sizes are stored sizes
+func (storage *Storage) ListPage(options oss.ListOptions) (*oss.ListResult, error) {
+	result, err := oss.ListPage(storage.storage, options)
+	if result != nil {
+		for _, object := range result.Objects {
+			storage.own(object)
+		}
+	}
+	return result, err
+}
+
+// GetEndpoint get endpoint
+func (storage *Storage) GetEndpoint() string {
+	return storage.storage.GetEndpoint()
+}
+
+// GetURL get URL of stored object, URLs of compressed objects serve compressed content without Content-Encoding, serve them with GetStreamEncoded instead
+func (storage *Storage) GetURL(path string) (string, error) {
+	return storage.GetURLCtx(context.Background(), path)
+}
+
+// GetURLCtx get URL of stored object, URLs of compressed objects serve compressed content without Content-Encoding, serve them with GetStreamEncoded instead
+func (storage *Storage) GetURLCtx(ctx context.Context, path string) (string, error) {
+	if storage.suffixed() {
+		stored, _, _, err := storage.resolve(path)
+		if err != nil {
+			return "", err
+		}
+		path = stored
+	} else if atomic.LoadInt32(&storage.metadata) == metadataUnknown {
+		// the object might be stored with key suffix as the storage drops metadata
+		if stored, _, _, err := storage.resolve(path); err == nil {
+			path = stored
+		}
+	}
+	return oss.WithContext(storage.storage).GetURLCtx(ctx, path)
+}
@@ -0,0 +1,223 @@
+package compression
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/qor/oss"
+	"github.com/qor/oss/filesystem"
+	"github.com/qor/oss/memory"
+	"github.com/qor/oss/tests"
+)
+
+var sample = strings.Repeat(`{"id": 1, "name": "sample", "tags": ["a", "b"]}`+"\n", 100)
+
+func newStorage(t *testing.T, storage oss.StorageInterface, config Config) *Storage {
+	compressed, err := New(storage, config)
+	if err != nil {
+		t.Fatalf("No error should happen when initialize compression, but got %v", err)
+	}
+	return compressed
+}
+
+func readAll(t *testing.T, storage oss.StorageInterface, path string) string {
+	stream, err := storage.GetStream(path)
+	if err != nil {
+		t.Fatalf("No error should happen when get %v, but got %v", path, err)
+	}
+	defer stream.Close()
+
+	content, err := ioutil.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("No error should happen when read %v, but got %v", path, err)
+	}
+	return string(content)
+}
+
+func TestAll(t *testing.T) {
+	tests.TestAll(newStorage(t, memory.New(), Config{}), t)
+	tests.TestAll(newStorage(t, memory.New(), Config{KeySuffix: true}), t)
+}
+
+func TestAllWithFileSystem(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compression")
+	if err != nil {
+		t.Fatalf("No error should happen when create temporary directory, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests.TestAll(newStorage(t, filesystem.New(dir), Config{KeySuffix: true}), t)
+	tests.TestAll(newStorage(t, filesystem.New(dir), Config{}), t)
+}
+
+func TestCompress(t *testing.T) {
+	for _, config := range []Config{{}, {Encoding: Zstd}, {KeySuffix: true}, {Encoding: Zstd, KeySuffix: true}} {
+		var (
+			backend = memory.New()
+			storage = newStorage(t, backend, config)
+			stored  = "/sample.json"
+		)
+
+		object, err := storage.Put("/sample.json", strings.NewReader(sample))
+		if err != nil {
+			t.Fatalf("No error should happen when put sample file, but got %v", err)
+		}
+		if object.Size != int64(len(sample)) || object.Path != "/sample.json" {
+			t.Errorf("Put object should have original size and path, but got %v, %v", object.Size, object.Path)
+		}
+		if sum := md5.Sum([]byte(sample)); object.Checksum == nil || !bytes.Equal(object.Checksum.MD5, sum[:]) {
+			t.Errorf("Put object should have checksum of original content, but got %+v", object.Checksum)
+		}
+
+		if config.KeySuffix {
+			stored += suffixes[storage.config.Encoding]
+		}
+		info, err := backend.Stat(stored)
+		if err != nil {
+			t.Fatalf("Compressed object should be stored at %v, but got %v", stored, err)
+		}
+		if info.Size >= int64(len(sample)) || (!config.KeySuffix && info.Metadata[MetadataKey] != storage.config.Encoding) {
+			t.Errorf("Stored object should be compressed with %v, but got %+v", storage.config.Encoding, info)
+		}
+
+		if content := readAll(t, storage, "/sample.json"); content != sample {
+			t.Errorf("Decompressed content should equal original content with config %+v", config)
+		}
+
+		file, err := storage.Get("/sample.json")
+		if err != nil {
+			t.Fatalf("No error should happen when get sample file, but got %v", err)
+		}
+		if content, _ := ioutil.ReadAll(file); string(content) != sample {
+			t.Errorf("Decompressed file should equal original content with config %+v", config)
+		}
+		file.Close()
+
+		if stream, err := storage.GetRange("/sample.json", 100, 10); err != nil {
+			t.Errorf("No error should happen when get range, but got %v", err)
+		} else if content, _ := ioutil.ReadAll(stream); string(content) != sample[100:110] {
+			t.Errorf("Range should be %q, but got %q", sample[100:110], content)
+		}
+
+		if objects, _ := storage.List("/"); len(objects) != 1 || objects[0].Path != "/sample.json" {
+			t.Errorf("Listed objects should have original paths, but got %v", objects)
+		}
+
+		if err := storage.Delete("/sample.json"); err != nil {
+			t.Errorf("No error should happen when delete sample file, but got %v", err)
+		}
+		if _, err := backend.Stat(stored); !errors.Is(err, oss.ErrNotExist) {
+			t.Errorf("Stored object should be deleted, but got %v", err)
+		}
+		if err := storage.Delete("/sample.json"); !errors.Is(err, oss.ErrNotExist) {
+			t.Errorf("Deleting missing object should fail with oss.ErrNotExist, but got %v", err)
+		}
+	}
+}
+
+func TestSkipCompression(t *testing.T) {
+	backend := memory.New()
+	storage := newStorage(t, backend, Config{MinSize: 100})
+
+	binary := bytes.Repeat([]byte{0, 1, 2, 3}, 100)
+	for path, content := range map[string][]byte{"/small.json": []byte(`{"id": 1}`), "/binary.bin": binary} {
+		storage.Put(path, bytes.NewReader(content))
+		if info, err := backend.Stat(path); err != nil || info.Size != int64(len(content)) || info.Metadata[MetadataKey] != "" {
+			t.Errorf("%v should be stored as is, but got %+v, %v", path, info, err)
+		}
+		if got := readAll(t, storage, path); got != string(content) {
+			t.Errorf("%v should be read as is, but got %q", path, got)
+		}
+	}
+}
+
+func TestMetadataDropped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compression")
+	if err != nil {
+		t.Fatalf("No error should happen when create temporary directory, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// file system storage drops metadata without Metadata
+	backend := filesystem.New(dir)
+	storage := newStorage(t, backend, Config{})
+
+	for _, path := range []string{"/sample.json", "/other.json"} {
+		if _, err := storage.Put(path, strings.NewReader(sample)); err != nil {
+			t.Fatalf("No error should happen when put %v, but got %v", path, err)
+		}
+		if _, err := backend.Stat(path + ".gz"); err != nil {
+			t.Errorf("%v should be stored with key suffix as metadata is dropped, but got %v", path, err)
+		}
+		if _, err := backend.Stat(path); !errors.Is(err, oss.ErrNotExist) {
+			t.Errorf("%v should not be stored without key suffix, but got %v", path, err)
+		}
+		if got := readAll(t, storage, path); got != sample {
+			t.Errorf("%v should be decompressed, but got %q", path, got)
+		}
+	}
+
+	// objects stored with key suffix are found by a new wrapper
+	reopened := newStorage(t, backend, Config{})
+	if got := readAll(t, reopened, "/sample.json"); got != sample {
+		t.Errorf("Object stored with key suffix should be found, but got %q", got)
+	}
+	if err := newStorage(t, backend, Config{}).Delete("/other.json"); err != nil {
+		t.Errorf("No error should happen when delete object stored with key suffix, but got %v", err)
+	}
+	if _, err := backend.Stat("/other.json.gz"); !errors.Is(err, oss.ErrNotExist) {
+		t.Errorf("Object stored with key suffix should be deleted, but got %v", err)
+	}
+
+	if objects, err := reopened.List("/"); err != nil || len(objects) != 1 || objects[0].Path != "/sample.json" {
+		t.Errorf("Listed objects should have original paths, but got %v, %v", objects, err)
+	}
+}
+
+func TestSkipEncoded(t *testing.T) {
+	backend := memory.New()
+	storage := newStorage(t, backend, Config{})
+
+	var encoded bytes.Buffer
+	compress(&encoded, strings.NewReader(sample), Gzip)
+
+	options := &oss.PutOptions{ContentType: "application/json", ContentEncoding: "gzip"}
+	if _, err := storage.PutWithOptions("/encoded.json", bytes.NewReader(encoded.Bytes()), options); err != nil {
+		t.Fatalf("No error should happen when put encoded file, but got %v", err)
+	}
+
+	info, err := backend.Stat("/encoded.json")
+	if err != nil || info.Size != int64(encoded.Len()) || info.Metadata[MetadataKey] != "" {
+		t.Errorf("Encoded file should be stored as is, but got %+v, %v", info, err)
+	}
+	if got := readAll(t, storage, "/encoded.json"); got != encoded.String() {
+		t.Errorf("Encoded file should be read as is, but got %q", got)
+	}
+}
+
+func TestGetStreamEncoded(t *testing.T) {
+	storage := newStorage(t, memory.New(), Config{})
+	storage.Put("/sample.json", strings.NewReader(sample))
+
+	for acceptEncoding, expected := range map[string]string{"gzip, deflate, br": Gzip, "*": Gzip, "br, gzip;q=0": "", "": ""} {
+		stream, encoding, err := storage.GetStreamEncoded(context.Background(), "/sample.json", acceptEncoding)
+		if err != nil {
+			t.Fatalf("No error should happen when get sample file, but got %v", err)
+		}
+		content, _ := ioutil.ReadAll(stream)
+		stream.Close()
+
+		if encoding != expected {
+			t.Errorf("Encoding with Accept-Encoding %q should be %q, but got %q", acceptEncoding, expected, encoding)
+		}
+		if (encoding == "") != (string(content) == sample) {
+			t.Errorf("Content should be compressed only if encoding is returned, Accept-Encoding %q", acceptEncoding)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package compression
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/qor/oss/tencent"
+)
+
+// fakeCOS stores objects with their Content-Type, Content-Encoding and metadata headers, and serves them like COS
+type fakeCOS struct {
+	mutex   sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	header http.Header
+	body   []byte
+}
+
+func (server *fakeCOS) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	switch req.Method {
+	case "PUT":
+		body, _ := ioutil.ReadAll(req.Body)
+		header := http.Header{}
+		for name := range req.Header {
+			if name == "Content-Type" || name == "Content-Encoding" || strings.HasPrefix(name, "X-Cos-Meta-") {
+				header.Set(name, req.Header.Get(name))
+			}
+		}
+		server.objects[req.URL.Path] = fakeObject{header: header, body: body}
+	case "DELETE":
+		delete(server.objects, req.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		object, ok := server.objects[req.URL.Path]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		for name := range object.header {
+			w.Header().Set(name, object.header.Get(name))
+		}
+		w.Write(object.body)
+	}
+}
+
+// rewriteTransport send requests to server with http.DefaultTransport, which decompresses gzip responses transparently
+type rewriteTransport struct {
+	server *url.URL
+}
+
+func (transport rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = transport.server.Scheme, transport.server.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestHTTPRoundTrip(t *testing.T) {
+	server := httptest.NewServer(&fakeCOS{objects: map[string]fakeObject{}})
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := tencent.New(&tencent.Config{Bucket: "bucket", Region: "region"})
+	client.Client = &http.Client{Transport: rewriteTransport{server: serverURL}}
+
+	for _, config := range []Config{{}, {KeySuffix: true}} {
+		storage := newStorage(t, client, config)
+		if _, err := storage.Put("/sample.json", strings.NewReader(sample)); err != nil {
+			t.Fatalf("No error should happen when put, but got %v", err)
+		}
+
+		if content := readAll(t, storage, "/sample.json"); content != sample {
+			t.Errorf("Content got through HTTP should be decompressed once, KeySuffix %v", config.KeySuffix)
+		}
+
+		if file, err := storage.Get("/sample.json"); err != nil {
+			t.Errorf("No error should happen when get, but got %v", err)
+		} else if content, _ := ioutil.ReadAll(file); string(content) != sample {
+			t.Errorf("File got through HTTP should be decompressed once, KeySuffix %v", config.KeySuffix)
+		}
+
+		stream, encoding, err := storage.GetStreamEncoded(context.Background(), "/sample.json", "gzip")
+		if err != nil || encoding != Gzip {
+			t.Fatalf("Stream should be gzip encoded, but got %v, %v", encoding, err)
+		}
+		reader, err := gzip.NewReader(stream)
+		if err != nil {
+			t.Fatalf("Stream labeled gzip should be gzip encoded, but got %v", err)
+		}
+		if content, _ := ioutil.ReadAll(reader); string(content) != sample {
+			t.Errorf("Gzip encoded stream should be decompressed to sample")
+		}
+		stream.Close()
+	}
+}
@@ -0,0 +1,118 @@
+package oss
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// ContextStorage define common API to operate storage with context, cancel the context to stop in-flight requests
+type ContextStorage interface {
+	StorageInterface
+	GetCtx(ctx context.Context, path string) (*os.File, error)
+	GetStreamCtx(ctx context.Context, path string) (io.ReadCloser, error)
+	PutCtx(ctx context.Context, path string, reader io.Reader) (*Object, error)
+	DeleteCtx(ctx context.Context, path string) error
+	ListCtx(ctx context.Context, path string) ([]*Object, error)
+	GetURLCtx(ctx context.Context, path string) (string, error)
+}
+
+// WithContext convert storage to ContextStorage, storages without native context support are wrapped
+// with an adapter that checks context before each call and aborts reads/writes once it is done
+func WithContext(storage StorageInterface) ContextStorage {
+	if contextStorage, ok := storage.(ContextStorage); ok {
+		return contextStorage
+	}
+	return contextAdapter{storage}
+}
+
+type contextAdapter struct {
+	StorageInterface
+}
+
+func (adapter contextAdapter) GetCtx(ctx context.Context, path string) (*os.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return adapter.Get(path)
+}
+
+func (adapter contextAdapter) GetStreamCtx(ctx context.Context, path string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stream, err := adapter.GetStream(path)
+	if err != nil {
+		return nil, err
+	}
+	return ContextReadCloser(ctx, stream), nil
+}
+
+func (adapter contextAdapter) PutCtx(ctx context.Context, path string, reader io.Reader) (*Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if reader != nil {
+		reader = ContextReader(ctx, reader)
+	}
+	return adapter.Put(path, reader)
+}
+
+func (adapter contextAdapter) DeleteCtx(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return adapter.Delete(path)
+}
+
+func (adapter contextAdapter) ListCtx(ctx context.Context, path string) ([]*Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return adapter.List(path)
+}
+
+func (adapter contextAdapter) GetURLCtx(ctx context.Context, path string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return adapter.GetURL(path)
+}
+
+// ContextReader returns a reader that fails with the context's error once ctx is done, nil reader is returned as is
+func ContextReader(ctx context.Context, reader io.Reader) io.Reader {
+	if reader == nil || ctx.Done() == nil {
+		return reader
+	}
+	return &contextReader{ctx: ctx, reader: reader}
+}
+
+// ContextReadCloser returns a ReadCloser that fails with the context's error once ctx is done, nil readCloser is returned as is
+func ContextReadCloser(ctx context.Context, readCloser io.ReadCloser) io.ReadCloser {
+	if readCloser == nil || ctx.Done() == nil {
+		return readCloser
+	}
+	return &contextReadCloser{contextReader: contextReader{ctx: ctx, reader: readCloser}, closer: readCloser}
+}
+
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (reader *contextReader) Read(p []byte) (int, error) {
+	if err := reader.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return reader.reader.Read(p)
+}
+
+type contextReadCloser struct {
+	contextReader
+	closer io.Closer
+}
+
+func (readCloser *contextReadCloser) Close() error {
+	return readCloser.closer.Close()
+}
@@ -0,0 +1,89 @@
+package oss_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/qor/oss"
+)
+
+// plainStorage implements oss.StorageInterface without context support
+type plainStorage struct {
+	content map[string][]byte
+}
+
+func (storage plainStorage) Get(path string) (*os.File, error) { return nil, os.ErrNotExist }
+
+func (storage plainStorage) GetStream(path string) (io.ReadCloser, error) {
+	content, ok := storage.content[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (storage plainStorage) Put(path string, reader io.Reader) (*oss.Object, error) {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	storage.content[path] = content
+	return &oss.Object{Path: path, StorageInterface: storage}, nil
+}
+
+func (storage plainStorage) List(path string) (objects []*oss.Object, err error) {
+	for key := range storage.content {
+		if strings.HasPrefix(key, "/"+strings.TrimPrefix(path, "/")) {
+			objects = append(objects, &oss.Object{Path: key, StorageInterface: storage})
+		}
+	}
+	return objects, nil
+}
+
+func (storage plainStorage) Delete(path string) error {
+	delete(storage.content, path)
+	return nil
+}
+
+func (storage plainStorage) GetURL(path string) (string, error) { return path, nil }
+func (storage plainStorage) GetEndpoint() string                { return "/" }
+
+func TestWithContext(t *testing.T) {
+	storage := oss.WithContext(plainStorage{content: map[string][]byte{}})
+
+	if _, err := storage.PutCtx(context.Background(), "/sample.txt", strings.NewReader("sample")); err != nil {
+		t.Errorf("No error should happen when put with context, but got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := storage.GetStreamCtx(ctx, "/sample.txt")
+	if err != nil {
+		t.Fatalf("No error should happen when get stream with context, but got %v", err)
+	}
+	cancel()
+
+	if _, err := ioutil.ReadAll(stream); err != context.Canceled {
+		t.Errorf("Reading stream after cancel should fail with context.Canceled, but got %v", err)
+	}
+
+	if _, err := storage.PutCtx(ctx, "/sample2.txt", strings.NewReader("sample")); err != context.Canceled {
+		t.Errorf("Put with cancelled context should fail with context.Canceled, but got %v", err)
+	}
+}
+
+func TestContextReaderNil(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if reader := oss.ContextReader(ctx, nil); reader != nil {
+		t.Errorf("Nil reader should be returned as is, but got %#v", reader)
+	}
+	if readCloser := oss.ContextReadCloser(ctx, nil); readCloser != nil {
+		t.Errorf("Nil ReadCloser should be returned as is, but got %#v", readCloser)
+	}
+}
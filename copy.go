@@ -0,0 +1,43 @@
+package oss
+
+// Copier is implemented by storages that could copy objects on the server side
+type Copier interface {
+	// Copy copy object from src to dst, dst is overwritten if it exists
+	Copy(src, dst string) error
+}
+
+// Mover is implemented by storages that could move objects on the server side
+type Mover interface {
+	// Move move object from src to dst, dst is overwritten if it exists
+	Move(src, dst string) error
+}
+
+// Copy copy object from src to dst with storage's Copy, storages don't implement Copier
+// fall back to streaming the object through current process
+func Copy(storage StorageInterface, src, dst string) error {
+	if copier, ok := storage.(Copier); ok {
+		return copier.Copy(src, dst)
+	}
+
+	stream, err := storage.GetStream(src)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	_, err = storage.Put(dst, stream)
+	return err
+}
+
+// Move move object from src to dst with storage's Move, storages don't implement Mover
+// fall back to Copy and then deleting src
+func Move(storage StorageInterface, src, dst string) error {
+	if mover, ok := storage.(Mover); ok {
+		return mover.Move(src, dst)
+	}
+
+	if err := Copy(storage, src, dst); err != nil {
+		return err
+	}
+	return storage.Delete(src)
+}
@@ -0,0 +1,27 @@
+package oss_test
+
+import (
+	"testing"
+
+	"github.com/qor/oss"
+)
+
+func TestCopyAndMove(t *testing.T) {
+	storage := plainStorage{content: map[string][]byte{"/sample.txt": []byte("sample")}}
+
+	if err := oss.Copy(storage, "/sample.txt", "/copied.txt"); err != nil {
+		t.Errorf("No error should happen when copy, but got %v", err)
+	} else if string(storage.content["/copied.txt"]) != "sample" {
+		t.Errorf("Copied file should have same content, but got %v", string(storage.content["/copied.txt"]))
+	}
+
+	if err := oss.Move(storage, "/copied.txt", "/moved.txt"); err != nil {
+		t.Errorf("No error should happen when move, but got %v", err)
+	} else if _, ok := storage.content["/copied.txt"]; ok || string(storage.content["/moved.txt"]) != "sample" {
+		t.Errorf("Moved file should only exist at new path")
+	}
+
+	if err := oss.Copy(storage, "/missing.txt", "/copied.txt"); err == nil {
+		t.Errorf("There should be an error when copy missing file")
+	}
+}
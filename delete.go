@@ -0,0 +1,111 @@
+package oss
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DeleteConcurrency number of objects deleted in parallel by DeleteMany for storages without batch delete, values less than 1 mean 1
+var DeleteConcurrency = 10
+
+// BatchDeleter is implemented by storages that could delete many objects with one request
+type BatchDeleter interface {
+	// DeleteMany delete objects at paths, returns errors of objects failed to delete keyed by path, nil if all deleted
+	DeleteMany(paths []string) map[string]error
+}
+
+// BatchError errors of objects failed to delete keyed by path
+type BatchError map[string]error
+
+func (e BatchError) Error() string {
+	paths := make([]string, 0, len(e))
+	for path := range e {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	if len(paths) == 1 {
+		return e[paths[0]].Error()
+	}
+	return fmt.Sprintf("oss: failed to delete %d objects, first error: %v", len(paths), e[paths[0]])
+}
+
+// DeleteMany delete objects at paths with storage's DeleteMany, storages don't implement BatchDeleter fall back
+// to deleting DeleteConcurrency objects in parallel. Returns errors of objects failed to delete keyed by path, nil if all deleted
+func DeleteMany(storage StorageInterface, paths []string) map[string]error {
+	if deleter, ok := storage.(BatchDeleter); ok {
+		return deleter.DeleteMany(paths)
+	}
+
+	var (
+		errs  = map[string]error{}
+		mutex sync.Mutex
+		wg    sync.WaitGroup
+		queue = make(chan string)
+		n     = DeleteConcurrency
+	)
+
+	if n < 1 {
+		n = 1
+	}
+
+	for i := 0; i < n && i < len(paths); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range queue {
+				if err := storage.Delete(path); err != nil {
+					mutex.Lock()
+					errs[path] = err
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, path := range paths {
+		queue <- path
+	}
+	close(queue)
+	wg.Wait()
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// DeletePrefix delete all objects whose path begins with prefix page by page, returns a BatchError if any object failed to delete
+func DeletePrefix(storage StorageInterface, prefix string) error {
+	var (
+		errs    = BatchError{}
+		options = ListOptions{Prefix: prefix}
+	)
+
+	for {
+		result, err := ListPage(storage, options)
+		if err != nil {
+			return err
+		}
+
+		paths := make([]string, 0, len(result.Objects))
+		for _, object := range result.Objects {
+			paths = append(paths, object.Path)
+		}
+
+		for path, err := range DeleteMany(storage, paths) {
+			errs[path] = err
+		}
+
+		if result.NextContinuationToken == "" {
+			break
+		}
+		options.ContinuationToken = result.NextContinuationToken
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
@@ -0,0 +1,73 @@
+package oss_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/qor/oss"
+)
+
+// lockedStorage is plainStorage safe for parallel deletes, deleting paths in failures fails
+type lockedStorage struct {
+	plainStorage
+	mutex    *sync.Mutex
+	failures map[string]bool
+}
+
+func (storage lockedStorage) Delete(path string) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	if storage.failures[path] {
+		return errors.New("failed to delete")
+	}
+	return storage.plainStorage.Delete(path)
+}
+
+func TestDeleteMany(t *testing.T) {
+	storage := lockedStorage{plainStorage: plainStorage{content: map[string][]byte{}}, mutex: &sync.Mutex{}, failures: map[string]bool{"/a/7.txt": true}}
+
+	var paths []string
+	for i := 0; i < 25; i++ {
+		path := fmt.Sprintf("/a/%v.txt", i)
+		storage.content[path] = []byte("sample")
+		paths = append(paths, path)
+	}
+	storage.content["/b/1.txt"] = []byte("sample")
+
+	errs := oss.DeleteMany(storage, paths)
+	if len(errs) != 1 || errs["/a/7.txt"] == nil {
+		t.Errorf("Only /a/7.txt should fail to delete, but got %v", errs)
+	}
+
+	if len(storage.content) != 2 {
+		t.Errorf("Only /a/7.txt and /b/1.txt should be left, but got %v objects", len(storage.content))
+	}
+
+	if err := oss.DeletePrefix(storage, "/a/"); err == nil {
+		t.Errorf("There should be an error when delete prefix with an object failed to delete")
+	} else if _, ok := err.(oss.BatchError)["/a/7.txt"]; !ok {
+		t.Errorf("Error should include /a/7.txt, but got %v", err)
+	}
+
+	delete(storage.failures, "/a/7.txt")
+	if err := oss.DeletePrefix(storage, "/a/"); err != nil {
+		t.Errorf("No error should happen when delete prefix, but got %v", err)
+	}
+
+	if _, ok := storage.content["/b/1.txt"]; !ok || len(storage.content) != 1 {
+		t.Errorf("Only objects under /a/ should be deleted, but got %v objects", len(storage.content))
+	}
+}
+
+func TestDeleteManyWithoutConcurrency(t *testing.T) {
+	defer func(concurrency int) { oss.DeleteConcurrency = concurrency }(oss.DeleteConcurrency)
+	oss.DeleteConcurrency = 0
+
+	storage := plainStorage{content: map[string][]byte{"/a.txt": []byte("a"), "/b.txt": []byte("b")}}
+	if errs := oss.DeleteMany(storage, []string{"/a.txt", "/b.txt"}); errs != nil || len(storage.content) != 0 {
+		t.Errorf("Objects should be deleted one by one if DeleteConcurrency is less than 1, but got %v, %v objects left", errs, len(storage.content))
+	}
+}
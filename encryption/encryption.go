@@ -0,0 +1,371 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/qor/oss"
+)
+
+var (
+	_ oss.ContextStorage = (*Storage)(nil)
+	_ oss.Stater         = (*Storage)(nil)
+	_ oss.PageLister     = (*Storage)(nil)
+	_ oss.OptionsPutter  = (*Storage)(nil)
+	_ oss.Copier         = (*Storage)(nil)
+	_ oss.Mover          = (*Storage)(nil)
+	_ oss.RangeGetter    = (*Storage)(nil)
+	_ oss.BatchDeleter   = (*Storage)(nil)
+)
+
+// DefaultChunkSize default bytes of plaintext per encrypted frame
+const DefaultChunkSize = 64 * 1024
+
+// listConcurrency number of headers read in parallel to get decrypted sizes of listed objects
+const listConcurrency = 10
+
+// Config encryption config
+type Config struct {
+	// Keys wraps per-object data keys
+	Keys KeyProvider
+	// ChunkSize bytes of plaintext per encrypted frame, range reads download whole frames, default is DefaultChunkSize
+	ChunkSize int
+}
+
+// Storage client-side encryption wrapper of storage. Each object is encrypted with its own AES-256-GCM data key,
+// which is wrapped with Keys and stored in the object's header, so objects never leave the process unencrypted.
+// Get, GetStream, GetRange and Stat decrypt transparently, List and ListPage read objects' headers to return decrypted
+// sizes, and URLs of GetURL serve encrypted content
+type Storage struct {
+	storage oss.StorageInterface
+	config  Config
+}
+
+// New initialize encryption wrapper of storage
+func New(storage oss.StorageInterface, config Config) (*Storage, error) {
+	if config.Keys == nil {
+		return nil, errors.New("encryption: no key provider given")
+	}
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = DefaultChunkSize
+	}
+	return &Storage{storage: storage, config: config}, nil
+}
+
+// own make object's methods go through the encryption wrapper
+func (storage *Storage) own(object *oss.Object) *oss.Object {
+	if object != nil {
+		object.StorageInterface = storage
+	}
+	return object
+}
+
+// readHeader read raw header of object at path with range reads, usually with one request as headers are small
+func (storage *Storage) readHeader(path string) ([]byte, error) {
+	data, err := storage.readRange(path, 0, 1024)
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := parsePrefix(data)
+	if err != nil {
+		return nil, oss.NewError("get", path, 0, err)
+	}
+
+	if len(data) < prefixSize+length {
+		rest, err := storage.readRange(path, int64(len(data)), int64(prefixSize+length-len(data)))
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, rest...)
+	}
+
+	if len(data) < prefixSize+length {
+		return nil, oss.NewError("get", path, 0, ErrCorrupted)
+	}
+	return data[prefixSize : prefixSize+length], nil
+}
+
+// envelope read header of object at path and unwrap its data key
+func (storage *Storage) envelope(path string) (*envelope, error) {
+	raw, err := storage.readHeader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := openEnvelope(storage.config.Keys, raw)
+	return env, oss.NewError("get", path, 0, err)
+}
+
+// decryptSizes set decrypted sizes of objects from their headers, read in parallel, sizes of objects
+// failed to read are kept as is
+func (storage *Storage) decryptSizes(objects []*oss.Object) {
+	var (
+		wg    sync.WaitGroup
+		queue = make(chan *oss.Object)
+	)
+
+	for i := 0; i < listConcurrency && i < len(objects); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for object := range queue {
+				if raw, err := storage.readHeader(object.Path); err == nil {
+					if h, err := parseHeader(raw); err == nil {
+						object.Size = plainSize(object.Size, int64(prefixSize+len(raw)), h.ChunkSize)
+					}
+				}
+			}
+		}()
+	}
+
+	for _, object := range objects {
+		if object.Size > 0 {
+			queue <- object
+		}
+	}
+	close(queue)
+	wg.Wait()
+}
+
+func (storage *Storage) readRange(path string, offset, length int64) ([]byte, error) {
+	stream, err := oss.GetRange(storage.storage, path, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	return ioutil.ReadAll(stream)
+}
+
+// Get receive decrypted file with given path, the content is decrypted into a temporary file that is removed once closed
+func (storage *Storage) Get(path string) (*os.File, error) {
+	return storage.GetCtx(context.Background(), path)
+}
+
+// GetCtx receive decrypted file with given path, the content is decrypted into a temporary file that is removed once closed
+func (storage *Storage) GetCtx(ctx context.Context, path string) (*os.File, error) {
+	stream, err := storage.GetStreamCtx(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	file, err := ioutil.TempFile("", "encryption*"+filepath.Ext(path))
+	if err != nil {
+		return nil, err
+	}
+	// decrypted content never stays on disk
+	os.Remove(file.Name())
+
+	if _, err = io.Copy(file, stream); err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, oss.NewError("get", path, 0, err)
+	}
+	return file, nil
+}
+
+// GetStream get decrypted file as stream, reading fails with ErrCorrupted if the object is tampered
+func (storage *Storage) GetStream(path string) (io.ReadCloser, error) {
+	return storage.GetStreamCtx(context.Background(), path)
+}
+
+// GetStreamCtx get decrypted file as stream, reading fails with ErrCorrupted if the object is tampered
+func (storage *Storage) GetStreamCtx(ctx context.Context, path string) (io.ReadCloser, error) {
+	stream, err := oss.WithContext(storage.storage).GetStreamCtx(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := readEnvelope(storage.config.Keys, stream)
+	if err != nil {
+		stream.Close()
+		return nil, oss.NewError("get", path, 0, err)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{newDecrypter(env, stream, 0), stream}, nil
+}
+
+// GetRange get length bytes of decrypted object starting at offset, only frames containing the range are downloaded
+func (storage *Storage) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	if length == 0 {
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	env, err := storage.envelope(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		chunkSize = int64(env.ChunkSize)
+		frameSize = int64(env.frameSize())
+		first     = offset / chunkSize
+		start     = env.size() + first*frameSize
+		size      = int64(-1)
+	)
+
+	if length > 0 {
+		size = ((offset+length-1)/chunkSize - first + 1) * frameSize
+	}
+
+	stream, err := oss.GetRange(storage.storage, path, start, size)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypter := newDecrypter(env, stream, uint32(first))
+	decrypter.allowEmpty = first > 0
+	if _, err := io.CopyN(ioutil.Discard, decrypter, offset-first*chunkSize); err != nil {
+		stream.Close()
+		if err == io.EOF {
+			// offset is beyond the end
+			return ioutil.NopCloser(bytes.NewReader(nil)), nil
+		}
+		return nil, oss.NewError("get", path, 0, err)
+	}
+
+	var reader io.Reader = decrypter
+	if length > 0 {
+		reader = io.LimitReader(decrypter, length)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{reader, stream}, nil
+}
+
+// Put encrypt a reader and store it into given path
+func (storage *Storage) Put(path string, reader io.Reader) (*oss.Object, error) {
+	return storage.PutCtx(context.Background(), path, reader)
+}
+
+// PutCtx encrypt a reader and store it into given path
+func (storage *Storage) PutCtx(ctx context.Context, path string, reader io.Reader) (*oss.Object, error) {
+	return storage.put(path, reader, func(encrypted io.Reader) (*oss.Object, error) {
+		return oss.WithContext(storage.storage).PutCtx(ctx, path, encrypted)
+	})
+}
+
+// PutWithOptions encrypt a reader and store it into given path with options, options and metadata are not encrypted
+func (storage *Storage) PutWithOptions(path string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	return storage.put(path, reader, func(encrypted io.Reader) (*oss.Object, error) {
+		return oss.PutWithOptions(storage.storage, path, encrypted, options)
+	})
+}
+
+func (storage *Storage) put(path string, reader io.Reader, put func(encrypted io.Reader) (*oss.Object, error)) (*oss.Object, error) {
+	env, err := newEnvelope(storage.config.Keys, storage.config.ChunkSize)
+	if err != nil {
+		return nil, oss.NewError("put", path, 0, err)
+	}
+
+	plain := oss.NewChecksumReader(reader)
+	object, err := put(newEncrypter(env, plain))
+	if object != nil {
+		object.Size = plain.Size()
+		object.Checksum = plain.Checksum()
+	}
+	return storage.own(object), err
+}
+
+// Stat get object's metadata, Size is the decrypted size
+func (storage *Storage) Stat(path string) (*oss.Object, error) {
+	object, err := oss.Stat(storage.storage, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if object.Size > 0 {
+		raw, err := storage.readHeader(path)
+		if err != nil {
+			return nil, err
+		}
+
+		h, err := parseHeader(raw)
+		if err != nil {
+			return nil, oss.NewError("stat", path, 0, err)
+		}
+		object.Size = plainSize(object.Size, int64(prefixSize+len(raw)), h.ChunkSize)
+	}
+	return storage.own(object), nil
+}
+
+// Delete delete object
+func (storage *Storage) Delete(path string) error {
+	return storage.DeleteCtx(context.Background(), path)
+}
+
+// DeleteCtx delete object
+func (storage *Storage) DeleteCtx(ctx context.Context, path string) error {
+	return oss.WithContext(storage.storage).DeleteCtx(ctx, path)
+}
+
+// DeleteMany delete objects at paths
+func (storage *Storage) DeleteMany(paths []string) map[string]error {
+	return oss.DeleteMany(storage.storage, paths)
+}
+
+// Copy copy object from src to dst, the encrypted content is copied as is
+func (storage *Storage) Copy(src, dst string) error {
+	return oss.Copy(storage.storage, src, dst)
+}
+
+// Move move object from src to dst, the encrypted content is moved as is
+func (storage *Storage) Move(src, dst string) error {
+	return oss.Move(storage.storage, src, dst)
+}
+
+// List list all objects under current path with decrypted sizes
+func (storage *Storage) List(path string) ([]*oss.Object, error) {
+	return storage.ListCtx(context.Background(), path)
+}
+
+// ListCtx list all objects under current path with decrypted sizes
+func (storage *Storage) ListCtx(ctx context.Context, path string) ([]*oss.Object, error) {
+	objects, err := oss.WithContext(storage.storage).ListCtx(ctx, path)
+	storage.decryptSizes(objects)
+	for _, object := range objects {
+		storage.own(object)
+	}
+	return objects, err
+}
+
+// ListPage list a page of objects with decrypted sizes
+func (storage *Storage) ListPage(options oss.ListOptions) (*oss.ListResult, error) {
+	result, err := oss.ListPage(storage.storage, options)
+	if result != nil {
+		storage.decryptSizes(result.Objects)
+		for _, object := range result.Objects {
+			storage.own(object)
+		}
+	}
+	return result, err
+}
+
+// GetEndpoint get endpoint
+func (storage *Storage) GetEndpoint() string {
+	return storage.storage.GetEndpoint()
+}
+
+// GetURL get URL, it serves encrypted content
+func (storage *Storage) GetURL(path string) (string, error) {
+	return storage.GetURLCtx(context.Background(), path)
+}
+
+// GetURLCtx get URL, it serves encrypted content
+func (storage *Storage) GetURLCtx(ctx context.Context, path string) (string, error) {
+	return oss.WithContext(storage.storage).GetURLCtx(ctx, path)
+}
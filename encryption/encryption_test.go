@@ -0,0 +1,214 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qor/oss"
+	"github.com/qor/oss/memory"
+	"github.com/qor/oss/tests"
+)
+
+func newKey() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+func newStorage(t *testing.T, storage oss.StorageInterface, chunkSize int) *Storage {
+	keyring, err := NewKeyring("current", map[string][]byte{"current": newKey()})
+	if err != nil {
+		t.Fatalf("No error should happen when initialize keyring, but got %v", err)
+	}
+
+	encrypted, err := New(storage, Config{Keys: keyring, ChunkSize: chunkSize})
+	if err != nil {
+		t.Fatalf("No error should happen when initialize encryption, but got %v", err)
+	}
+	return encrypted
+}
+
+func readAll(t *testing.T, storage oss.StorageInterface, path string) []byte {
+	stream, err := storage.GetStream(path)
+	if err != nil {
+		t.Fatalf("No error should happen when get %v, but got %v", path, err)
+	}
+	defer stream.Close()
+
+	content, err := ioutil.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("No error should happen when read %v, but got %v", path, err)
+	}
+	return content
+}
+
+func TestAll(t *testing.T) {
+	tests.TestAll(newStorage(t, memory.New(), 16), t)
+}
+
+func TestEncrypt(t *testing.T) {
+	for _, size := range []int{0, 1, 15, 16, 17, 100} {
+		var (
+			backend   = memory.New()
+			storage   = newStorage(t, backend, 16)
+			plaintext = bytes.Repeat([]byte("s"), size)
+		)
+
+		object, err := storage.Put("/sample.txt", bytes.NewReader(plaintext))
+		if err != nil {
+			t.Fatalf("No error should happen when put sample file, but got %v", err)
+		}
+		if object.Size != int64(size) {
+			t.Errorf("Put object's size should be %v, but got %v", size, object.Size)
+		}
+		if sum := md5.Sum(plaintext); object.Checksum == nil || !bytes.Equal(object.Checksum.MD5, sum[:]) {
+			t.Errorf("Put object should have checksum of plaintext, but got %+v", object.Checksum)
+		}
+
+		if encrypted := readAll(t, backend, "/sample.txt"); !bytes.HasPrefix(encrypted, []byte(magic)) || (size > 16 && bytes.Contains(encrypted, plaintext)) {
+			t.Errorf("Stored object should be encrypted")
+		}
+
+		if content := readAll(t, storage, "/sample.txt"); !bytes.Equal(content, plaintext) {
+			t.Errorf("Decrypted content of %v bytes should equal plaintext, but got %q", size, content)
+		}
+
+		file, err := storage.Get("/sample.txt")
+		if err != nil {
+			t.Fatalf("No error should happen when get sample file, but got %v", err)
+		}
+		if content, _ := ioutil.ReadAll(file); !bytes.Equal(content, plaintext) {
+			t.Errorf("Decrypted file of %v bytes should equal plaintext, but got %q", size, content)
+		}
+		file.Close()
+
+		if object, err := storage.Stat("/sample.txt"); err != nil || object.Size != int64(size) {
+			t.Errorf("Stat should return decrypted size %v, but got %v, %v", size, object, err)
+		}
+	}
+}
+
+func TestGetRange(t *testing.T) {
+	var (
+		storage   = newStorage(t, memory.New(), 10)
+		plaintext = []byte(strings.Repeat("0123456789", 9) + "abcde")
+	)
+	storage.Put("/sample.txt", bytes.NewReader(plaintext))
+
+	for _, c := range [][2]int64{{0, 5}, {0, 10}, {3, 10}, {10, 10}, {9, 2}, {42, 30}, {90, -1}, {0, -1}, {50, 100}, {95, 5}, {200, 5}} {
+		offset, length := c[0], c[1]
+
+		expected := plaintext[min(offset, int64(len(plaintext))):]
+		if length >= 0 && length < int64(len(expected)) {
+			expected = expected[:length]
+		}
+
+		stream, err := storage.GetRange("/sample.txt", offset, length)
+		if err != nil {
+			t.Errorf("No error should happen when get range %v, %v, but got %v", offset, length, err)
+			continue
+		}
+		content, err := ioutil.ReadAll(stream)
+		stream.Close()
+
+		if err != nil || !bytes.Equal(content, expected) {
+			t.Errorf("Range %v, %v should be %q, but got %q, %v", offset, length, expected, content, err)
+		}
+	}
+}
+
+func min(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func TestTampered(t *testing.T) {
+	backend := memory.New()
+	storage := newStorage(t, backend, 16)
+	storage.Put("/sample.txt", strings.NewReader(strings.Repeat("sample", 10)))
+	encrypted := readAll(t, backend, "/sample.txt")
+
+	tampered := append([]byte{}, encrypted...)
+	tampered[len(tampered)-20] ^= 1
+	backend.Put("/tampered.txt", bytes.NewReader(tampered))
+
+	env, _ := readEnvelope(storage.config.Keys, bytes.NewReader(encrypted))
+	truncated := encrypted[:env.size()+int64(env.frameSize())*2]
+	backend.Put("/truncated.txt", bytes.NewReader(truncated))
+
+	backend.Put("/plain.txt", strings.NewReader("plain"))
+
+	for path, expected := range map[string]error{"/tampered.txt": ErrCorrupted, "/truncated.txt": ErrCorrupted, "/plain.txt": ErrNotEncrypted} {
+		stream, err := storage.GetStream(path)
+		if err == nil {
+			_, err = ioutil.ReadAll(stream)
+			stream.Close()
+		}
+		if !errors.Is(err, expected) {
+			t.Errorf("Reading %v should fail with %v, but got %v", path, expected, err)
+		}
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	var (
+		backend = memory.New()
+		oldKey  = newKey()
+	)
+
+	keyring, _ := NewKeyring("old", map[string][]byte{"old": oldKey})
+	storage, _ := New(backend, Config{Keys: keyring})
+	storage.Put("/sample.txt", strings.NewReader("sample"))
+
+	keyring, _ = NewKeyring("new", map[string][]byte{"old": oldKey, "new": newKey()})
+	storage, _ = New(backend, Config{Keys: keyring})
+	if content := readAll(t, storage, "/sample.txt"); string(content) != "sample" {
+		t.Errorf("Objects encrypted with previous key should be readable after rotation, but got %q", content)
+	}
+
+	keyring, _ = NewKeyring("other", map[string][]byte{"other": newKey()})
+	storage, _ = New(backend, Config{Keys: keyring})
+	if _, err := storage.GetStream("/sample.txt"); err == nil {
+		t.Errorf("Objects encrypted with unknown key should not be readable")
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keyring")
+	if err != nil {
+		t.Fatalf("No error should happen when create temporary directory, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "keyring.json")
+	ioutil.WriteFile(path, []byte(fmt.Sprintf(`{"current": "2024", "keys": {"2024": %q}}`, base64.StdEncoding.EncodeToString(newKey()))), 0600)
+
+	keyring, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatalf("No error should happen when load keyring, but got %v", err)
+	}
+
+	id, wrapped, err := keyring.WrapKey([]byte("data key"))
+	if err != nil || id != "2024" {
+		t.Fatalf("Data key should be wrapped with current key, but got %v, %v", id, err)
+	}
+	if dataKey, err := keyring.UnwrapKey(id, wrapped); err != nil || string(dataKey) != "data key" {
+		t.Errorf("Wrapped data key should be unwrapped, but got %q, %v", dataKey, err)
+	}
+
+	ioutil.WriteFile(path, []byte(`{"current": "2024", "keys": {"2024": "c2hvcnQ="}}`), 0600)
+	if _, err := LoadKeyring(path); err == nil {
+		t.Errorf("Keys not of 32 bytes should be rejected")
+	}
+}
@@ -0,0 +1,95 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// KeyProvider wraps per-object data keys with master keys, e.g. a KMS or a local Keyring
+type KeyProvider interface {
+	// WrapKey encrypt data key with current master key, returns the master key's id and the wrapped data key
+	WrapKey(dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypt data key wrapped with master key of keyID
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Keyring local KeyProvider wrapping data keys with AES-256-GCM master keys. New objects use the current key,
+// previous keys are kept to read objects encrypted before key rotation
+type Keyring struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewKeyring initialize Keyring with 32 bytes master keys keyed by id, current is id of the key to wrap new data keys
+func NewKeyring(current string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("encryption: current key %q not found in keyring", current)
+	}
+
+	keyring := &Keyring{current: current, keys: map[string][]byte{}}
+	for id, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption: key %q should be 32 bytes, but got %d", id, len(key))
+		}
+		keyring.keys[id] = key
+	}
+	return keyring, nil
+}
+
+// LoadKeyring load Keyring from JSON file with base64 encoded keys like
+//
+//	{"current": "2024-01", "keys": {"2023-01": "...", "2024-01": "..."}}
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Current string            `json:"current"`
+		Keys    map[string][]byte `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("encryption: invalid keyring file %v: %w", path, err)
+	}
+	return NewKeyring(file.Current, file.Keys)
+}
+
+// WrapKey encrypt data key with current master key
+func (keyring *Keyring) WrapKey(dataKey []byte) (string, []byte, error) {
+	aead, err := newAEAD(keyring.keys[keyring.current])
+	if err != nil {
+		return "", nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return keyring.current, aead.Seal(nonce, nonce, dataKey, []byte(keyring.current)), nil
+}
+
+// UnwrapKey decrypt data key wrapped with master key of keyID
+func (keyring *Keyring) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := keyring.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("encryption: key %q not found in keyring", keyID)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("encryption: invalid wrapped key")
+	}
+	dataKey, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, errors.New("encryption: invalid wrapped key")
+	}
+	return dataKey, nil
+}
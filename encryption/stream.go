@@ -0,0 +1,312 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Encrypted objects begin with magic, big endian uint32 length of JSON encoded header, and the header, followed by
+// frames of ChunkSize bytes of plaintext encrypted with AES-256-GCM using the header as additional data. Frame's nonce
+// is the header's 7 bytes nonce prefix, big endian uint32 frame index, and 1 if it is the last frame or 0, so frames
+// couldn't be reordered and truncating the object is detected
+const (
+	magic          = "QOE1"
+	prefixSize     = len(magic) + 4
+	maxHeaderSize  = 64 * 1024
+	noncePrefixLen = 7
+	// overhead size of GCM tag of each frame
+	overhead = 16
+)
+
+var (
+	// ErrNotEncrypted returned when reading an object which isn't encrypted by this package
+	ErrNotEncrypted = errors.New("encryption: object is not encrypted")
+	// ErrCorrupted returned when reading an encrypted object which is corrupted, tampered or truncated
+	ErrCorrupted = errors.New("encryption: object is corrupted")
+)
+
+type header struct {
+	KeyID      string `json:"key_id"`
+	WrappedKey []byte `json:"wrapped_key"`
+	ChunkSize  int    `json:"chunk_size"`
+	Nonce      []byte `json:"nonce"`
+}
+
+// envelope parsed header of an encrypted object
+type envelope struct {
+	header
+	// raw encoded header, additional data of frames
+	raw  []byte
+	aead cipher.AEAD
+}
+
+// size of encoded magic, header length and header
+func (env *envelope) size() int64 {
+	return int64(prefixSize + len(env.raw))
+}
+
+// frameSize size of an encrypted full frame
+func (env *envelope) frameSize() int {
+	return env.ChunkSize + overhead
+}
+
+// plainSize get plaintext size from size of encrypted object, whose header is of headerSize and frames are of chunkSize
+func plainSize(size, headerSize int64, chunkSize int) int64 {
+	var (
+		body   = size - headerSize
+		frame  = int64(chunkSize + overhead)
+		frames = body / frame
+		rest   = body % frame
+	)
+
+	if rest >= overhead {
+		return frames*int64(chunkSize) + rest - overhead
+	}
+	return frames * int64(chunkSize)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// newEnvelope generate a data key wrapped with keys for a new object
+func newEnvelope(keys KeyProvider, chunkSize int) (*envelope, error) {
+	dataKey := make([]byte, 32)
+	nonce := make([]byte, noncePrefixLen)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	keyID, wrapped, err := keys.WrapKey(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &envelope{header: header{KeyID: keyID, WrappedKey: wrapped, ChunkSize: chunkSize, Nonce: nonce}}
+	if env.raw, err = json.Marshal(env.header); err != nil {
+		return nil, err
+	}
+	env.aead, err = newAEAD(dataKey)
+	return env, err
+}
+
+// parsePrefix get header length from the beginning of an encrypted object
+func parsePrefix(prefix []byte) (int, error) {
+	if len(prefix) < prefixSize || string(prefix[:len(magic)]) != magic {
+		return 0, ErrNotEncrypted
+	}
+
+	length := binary.BigEndian.Uint32(prefix[len(magic):])
+	if length == 0 || length > maxHeaderSize {
+		return 0, ErrCorrupted
+	}
+	return int(length), nil
+}
+
+// parseHeader parse raw header without unwrapping its data key
+func parseHeader(raw []byte) (header, error) {
+	var h header
+	if err := json.Unmarshal(raw, &h); err != nil || h.ChunkSize <= 0 || len(h.Nonce) != noncePrefixLen {
+		return h, ErrCorrupted
+	}
+	return h, nil
+}
+
+// openEnvelope parse raw header and unwrap its data key with keys
+func openEnvelope(keys KeyProvider, raw []byte) (*envelope, error) {
+	h, err := parseHeader(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := keys.UnwrapKey(h.KeyID, h.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &envelope{header: h, raw: raw}
+	env.aead, err = newAEAD(dataKey)
+	return env, err
+}
+
+// readEnvelope read header from the beginning of an encrypted object's stream
+func readEnvelope(keys KeyProvider, reader io.Reader) (*envelope, error) {
+	prefix := make([]byte, prefixSize)
+	if _, err := io.ReadFull(reader, prefix); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrNotEncrypted
+		}
+		return nil, err
+	}
+
+	length, err := parsePrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, length)
+	if _, err := io.ReadFull(reader, raw); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrCorrupted
+		}
+		return nil, err
+	}
+	return openEnvelope(keys, raw)
+}
+
+func (env *envelope) nonce(index uint32, last bool) []byte {
+	nonce := make([]byte, env.aead.NonceSize())
+	copy(nonce, env.Nonce)
+	binary.BigEndian.PutUint32(nonce[noncePrefixLen:], index)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// encrypter reader encrypts plaintext read from source, it returns the header and then frames
+type encrypter struct {
+	env    *envelope
+	source *bufio.Reader
+	chunk  []byte
+	out    []byte
+	index  uint32
+	done   bool
+}
+
+func newEncrypter(env *envelope, source io.Reader) *encrypter {
+	var prefix bytes.Buffer
+	prefix.WriteString(magic)
+	binary.Write(&prefix, binary.BigEndian, uint32(len(env.raw)))
+	prefix.Write(env.raw)
+
+	if source == nil {
+		source = bytes.NewReader(nil)
+	}
+
+	return &encrypter{
+		env:    env,
+		source: bufio.NewReader(source),
+		chunk:  make([]byte, env.ChunkSize),
+		out:    prefix.Bytes(),
+	}
+}
+
+func (encrypter *encrypter) Read(p []byte) (int, error) {
+	for len(encrypter.out) == 0 {
+		if encrypter.done {
+			return 0, io.EOF
+		}
+		if err := encrypter.seal(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, encrypter.out)
+	encrypter.out = encrypter.out[n:]
+	return n, nil
+}
+
+// seal encrypt next chunk of source into out
+func (encrypter *encrypter) seal() error {
+	n, err := io.ReadFull(encrypter.source, encrypter.chunk)
+	switch err {
+	case nil:
+		// it is the last frame if nothing follows
+		if _, err = encrypter.source.Peek(1); err == io.EOF {
+			encrypter.done = true
+		} else if err != nil {
+			return err
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+		encrypter.done = true
+	default:
+		return err
+	}
+
+	if !encrypter.done && encrypter.index == ^uint32(0) {
+		return errors.New("encryption: object is too large")
+	}
+
+	encrypter.out = encrypter.env.aead.Seal(encrypter.out[:0], encrypter.env.nonce(encrypter.index, encrypter.done), encrypter.chunk[:n], encrypter.env.raw)
+	encrypter.index++
+	return nil
+}
+
+// decrypter reader decrypts frames read from source, starting from frame of index
+type decrypter struct {
+	env    *envelope
+	source io.Reader
+	frame  []byte
+	out    []byte
+	index  uint32
+	last   bool
+	err    error
+	// allowEmpty source could be empty, e.g. range reads beyond the end
+	allowEmpty bool
+}
+
+func newDecrypter(env *envelope, source io.Reader, index uint32) *decrypter {
+	return &decrypter{env: env, source: source, frame: make([]byte, env.frameSize()), index: index}
+}
+
+func (decrypter *decrypter) Read(p []byte) (int, error) {
+	for len(decrypter.out) == 0 {
+		if decrypter.err != nil {
+			return 0, decrypter.err
+		}
+		if decrypter.last {
+			return 0, io.EOF
+		}
+		decrypter.err = decrypter.open()
+	}
+
+	n := copy(p, decrypter.out)
+	decrypter.out = decrypter.out[n:]
+	return n, nil
+}
+
+// open decrypt next frame from source into out
+func (decrypter *decrypter) open() error {
+	n, err := io.ReadFull(decrypter.source, decrypter.frame)
+	if err == io.EOF {
+		if decrypter.allowEmpty {
+			return io.EOF
+		}
+		// the last frame is missing
+		return ErrCorrupted
+	}
+	decrypter.allowEmpty = false
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	frame := decrypter.frame[:n]
+	if n == len(decrypter.frame) {
+		if decrypter.out, err = decrypter.env.aead.Open(decrypter.out[:0], decrypter.env.nonce(decrypter.index, false), frame, decrypter.env.raw); err == nil {
+			decrypter.index++
+			return nil
+		}
+	}
+
+	// full frames could be the last one
+	if decrypter.out, err = decrypter.env.aead.Open(decrypter.out[:0], decrypter.env.nonce(decrypter.index, true), frame, decrypter.env.raw); err != nil {
+		return ErrCorrupted
+	}
+	decrypter.last = true
+	return nil
+}
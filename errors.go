@@ -0,0 +1,102 @@
+package oss
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// Errors returned by storages, compare with errors.Is. ErrNotExist, ErrPermission and ErrAlreadyExists
+// are the os package's errors, so errors from the file system match them too
+var (
+	ErrNotExist           = os.ErrNotExist
+	ErrPermission         = os.ErrPermission
+	ErrAlreadyExists      = os.ErrExist
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrInvalidPath path is malformed or escapes the storage's root, e.g. contains ".." segments
+	ErrInvalidPath = errors.New("invalid path")
+)
+
+// Error records a failed storage operation, it matches its Kind with errors.Is and unwraps to the storage provider's error
+type Error struct {
+	Op   string
+	Path string
+	// Kind is one of ErrNotExist, ErrPermission, ErrAlreadyExists, ErrPreconditionFailed, ErrInvalidPath, or nil if unclassified
+	Kind error
+	// StatusCode HTTP status code returned by the storage provider, 0 if unknown
+	StatusCode int
+	Err        error
+}
+
+// NewError wrap provider's error err of operation op, the kind is derived from HTTP status code,
+// or from err itself if it already matches one of the errors of this package. Returns nil if err is nil
+func NewError(op, path string, statusCode int, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if ossErr, ok := err.(*Error); ok {
+		return ossErr
+	}
+
+	kind := KindFromStatus(statusCode)
+	if kind == nil {
+		for _, e := range []error{ErrNotExist, ErrPermission, ErrAlreadyExists, ErrPreconditionFailed, ErrInvalidPath} {
+			if errors.Is(err, e) {
+				kind = e
+				break
+			}
+		}
+	}
+
+	return &Error{Op: op, Path: path, Kind: kind, StatusCode: statusCode, Err: err}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("oss: %s %s: %v", e.Op, e.Path, e.Err)
+}
+
+// Unwrap returns the storage provider's error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the kind of the error
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+// KindFromStatus get error kind from HTTP status code, returns nil if the status has no corresponding kind
+func KindFromStatus(statusCode int) error {
+	switch statusCode {
+	case http.StatusNotFound, http.StatusGone:
+		return ErrNotExist
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrPermission
+	case http.StatusConflict:
+		return ErrAlreadyExists
+	case http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// HTTPStatus get HTTP status code to respond with for err
+func HTTPStatus(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, ErrPermission):
+		return http.StatusForbidden
+	case errors.Is(err, ErrAlreadyExists):
+		return http.StatusConflict
+	case errors.Is(err, ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, ErrInvalidPath):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
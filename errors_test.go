@@ -0,0 +1,52 @@
+package oss_test
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/qor/oss"
+)
+
+func TestNewError(t *testing.T) {
+	providerErr := errors.New("NoSuchKey: The specified key does not exist")
+
+	err := oss.NewError("get", "/sample.txt", http.StatusNotFound, providerErr)
+	if !errors.Is(err, oss.ErrNotExist) {
+		t.Errorf("Error with status 404 should match ErrNotExist, but got %v", err)
+	}
+	if errors.Is(err, oss.ErrPermission) {
+		t.Errorf("Error with status 404 should not match ErrPermission")
+	}
+	if !errors.Is(err, providerErr) {
+		t.Errorf("Error should unwrap to the provider's error")
+	}
+	if oss.HTTPStatus(err) != http.StatusNotFound {
+		t.Errorf("HTTP status should be 404, but got %v", oss.HTTPStatus(err))
+	}
+
+	_, pathErr := os.Open("/not-exist/sample.txt")
+	if err := oss.NewError("get", "/sample.txt", 0, pathErr); !errors.Is(err, oss.ErrNotExist) {
+		t.Errorf("Error wrapping os.PathError should match ErrNotExist, but got %v", err)
+	}
+
+	statusMap := map[int]error{
+		http.StatusForbidden:          oss.ErrPermission,
+		http.StatusConflict:           oss.ErrAlreadyExists,
+		http.StatusPreconditionFailed: oss.ErrPreconditionFailed,
+	}
+	for status, kind := range statusMap {
+		if err := oss.NewError("put", "/sample.txt", status, providerErr); !errors.Is(err, kind) || oss.HTTPStatus(err) != status {
+			t.Errorf("Error with status %v should match %v", status, kind)
+		}
+	}
+
+	if err := oss.NewError("put", "/sample.txt", http.StatusInternalServerError, providerErr); oss.HTTPStatus(err) != http.StatusInternalServerError {
+		t.Errorf("Unclassified error's HTTP status should be 500, but got %v", oss.HTTPStatus(err))
+	}
+
+	if oss.NewError("put", "/sample.txt", http.StatusNotFound, nil) != nil {
+		t.Errorf("NewError should return nil for nil error")
+	}
+}
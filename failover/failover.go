@@ -0,0 +1,458 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/qor/oss"
+)
+
+var (
+	_ oss.ContextStorage = (*Failover)(nil)
+	_ oss.Stater         = (*Failover)(nil)
+	_ oss.PageLister     = (*Failover)(nil)
+	_ oss.OptionsPutter  = (*Failover)(nil)
+	_ oss.Copier         = (*Failover)(nil)
+	_ oss.Mover          = (*Failover)(nil)
+	_ oss.RangeGetter    = (*Failover)(nil)
+	_ oss.BatchDeleter   = (*Failover)(nil)
+)
+
+// Config failover config
+type Config struct {
+	// Window number of latest operations of each storage the error rate is calculated from, default is 20
+	Window int
+	// MinRequests min number of operations in window before a storage could be marked as unhealthy, default is 5
+	MinRequests int
+	// FailureRate storage is marked as unhealthy once its error rate exceeds FailureRate, default is 0.5
+	FailureRate float64
+	// ProbeInterval interval to probe unhealthy storages, default is 10s
+	ProbeInterval time.Duration
+	// Probe check storage is healthy, default lists one object
+	Probe func(storage oss.StorageInterface) error
+	// IsFailure reports whether err is a failure of the storage that counts in error rate and fails over to next storage,
+	// default is any error except context errors and errors matching oss.ErrNotExist, ErrPermission, ErrAlreadyExists and ErrPreconditionFailed
+	IsFailure func(err error) bool
+}
+
+// Health health state of a storage
+type Health struct {
+	Endpoint string `json:"endpoint"`
+	Healthy  bool   `json:"healthy"`
+	// FailureRate error rate of latest operations in window
+	FailureRate float64 `json:"failure_rate"`
+	// Requests number of latest operations in window
+	Requests  int       `json:"requests"`
+	LastError string    `json:"last_error,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// Failover storage routes operations to the first healthy storage of an ordered list, and fails over to next ones
+// if it fails. A storage is marked as unhealthy (its circuit opens) once its error rate exceeds FailureRate, unhealthy
+// storages are skipped, and probed every ProbeInterval until they recover
+type Failover struct {
+	storages []*backend
+	config   Config
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type backend struct {
+	storage oss.StorageInterface
+
+	mutex     sync.Mutex
+	healthy   bool
+	outcomes  []bool // ring buffer of latest operations, true if failed
+	next      int
+	lastErr   error
+	changedAt time.Time
+}
+
+// New initialize Failover storage of storages ordered by priority, unhealthy storages are probed in background until Close
+func New(config Config, storages ...oss.StorageInterface) *Failover {
+	if config.Window <= 0 {
+		config.Window = 20
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = 5
+	}
+	if config.FailureRate <= 0 {
+		config.FailureRate = 0.5
+	}
+	if config.ProbeInterval <= 0 {
+		config.ProbeInterval = 10 * time.Second
+	}
+	if config.Probe == nil {
+		config.Probe = func(storage oss.StorageInterface) error {
+			_, err := oss.ListPage(storage, oss.ListOptions{MaxKeys: 1})
+			return err
+		}
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = IsFailure
+	}
+
+	failover := &Failover{config: config, stop: make(chan struct{}), done: make(chan struct{})}
+	now := time.Now()
+	for _, storage := range storages {
+		failover.storages = append(failover.storages, &backend{storage: storage, healthy: true, changedAt: now})
+	}
+
+	go failover.probe()
+	return failover
+}
+
+// IsFailure reports whether err is a failure of storage, errors of canceled contexts and classified errors, like
+// oss.ErrNotExist, are caused by the operation instead of storage's health
+func IsFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	for _, kind := range []error{oss.ErrNotExist, oss.ErrPermission, oss.ErrAlreadyExists, oss.ErrPreconditionFailed} {
+		if errors.Is(err, kind) {
+			return false
+		}
+	}
+	return true
+}
+
+// Close stop probing unhealthy storages
+func (failover *Failover) Close() error {
+	select {
+	case <-failover.stop:
+	default:
+		close(failover.stop)
+	}
+	<-failover.done
+	return nil
+}
+
+func (failover *Failover) probe() {
+	defer close(failover.done)
+
+	ticker := time.NewTicker(failover.config.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-failover.stop:
+			return
+		case <-ticker.C:
+			failover.Probe()
+		}
+	}
+}
+
+// Probe probe unhealthy storages now, storages that pass the probe are marked as healthy
+func (failover *Failover) Probe() {
+	for _, b := range failover.storages {
+		b.mutex.Lock()
+		healthy := b.healthy
+		b.mutex.Unlock()
+
+		if healthy {
+			continue
+		}
+
+		err := failover.config.Probe(b.storage)
+
+		b.mutex.Lock()
+		if err == nil {
+			b.healthy, b.outcomes, b.next, b.changedAt = true, nil, 0, time.Now()
+		} else {
+			b.lastErr = err
+		}
+		b.mutex.Unlock()
+	}
+}
+
+// Health get health state of storages in order
+func (failover *Failover) Health() []Health {
+	var healths []Health
+	for _, b := range failover.storages {
+		b.mutex.Lock()
+		health := Health{
+			Endpoint:    b.storage.GetEndpoint(),
+			Healthy:     b.healthy,
+			FailureRate: b.failureRate(),
+			Requests:    len(b.outcomes),
+			ChangedAt:   b.changedAt,
+		}
+		if b.lastErr != nil {
+			health.LastError = b.lastErr.Error()
+		}
+		b.mutex.Unlock()
+		healths = append(healths, health)
+	}
+	return healths
+}
+
+// failureRate error rate of outcomes in window, must be called with lock held
+func (b *backend) failureRate() float64 {
+	if len(b.outcomes) == 0 {
+		return 0
+	}
+
+	var failures int
+	for _, failed := range b.outcomes {
+		if failed {
+			failures++
+		}
+	}
+	return float64(failures) / float64(len(b.outcomes))
+}
+
+// record record outcome of an operation, mark storage as unhealthy if error rate exceeds FailureRate
+func (failover *Failover) record(b *backend, err error) {
+	failed := failover.config.IsFailure(err)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(b.outcomes) < failover.config.Window {
+		b.outcomes = append(b.outcomes, failed)
+	} else {
+		b.outcomes[b.next] = failed
+		b.next = (b.next + 1) % failover.config.Window
+	}
+	if failed {
+		b.lastErr = err
+	}
+
+	if b.healthy && len(b.outcomes) >= failover.config.MinRequests && b.failureRate() > failover.config.FailureRate {
+		b.healthy, b.changedAt = false, time.Now()
+	}
+}
+
+// do run operation on healthy storages in order until it doesn't fail, all storages are tried in order if none is healthy.
+// before is called before failing over to next storage, the operation is not failed over if it returns an error
+func (failover *Failover) do(before func() error, fn func(storage oss.StorageInterface) error) error {
+	var candidates []*backend
+	for _, b := range failover.storages {
+		b.mutex.Lock()
+		if b.healthy {
+			candidates = append(candidates, b)
+		}
+		b.mutex.Unlock()
+	}
+	if len(candidates) == 0 {
+		candidates = failover.storages
+	}
+
+	err := errors.New("failover: no storage")
+	for i, b := range candidates {
+		if i > 0 && before != nil {
+			if before() != nil {
+				return err
+			}
+		}
+
+		err = fn(b.storage)
+		failover.record(b, err)
+		if !failover.config.IsFailure(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// rewind get a function to rewind reader to its current offset, it fails if reader isn't io.Seeker
+func rewind(reader io.Reader) func() error {
+	seeker, ok := reader.(io.Seeker)
+	if !ok {
+		return func() error { return errors.New("failover: reader is not seekable") }
+	}
+
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	return func() error {
+		if err != nil {
+			return err
+		}
+		_, err := seeker.Seek(offset, io.SeekStart)
+		return err
+	}
+}
+
+// own make object's methods go through the failover
+func (failover *Failover) own(object *oss.Object) *oss.Object {
+	if object != nil {
+		object.StorageInterface = failover
+	}
+	return object
+}
+
+// Get receive file with given path
+func (failover *Failover) Get(path string) (*os.File, error) {
+	return failover.GetCtx(context.Background(), path)
+}
+
+// GetCtx receive file with given path
+func (failover *Failover) GetCtx(ctx context.Context, path string) (file *os.File, err error) {
+	err = failover.do(nil, func(storage oss.StorageInterface) (err error) {
+		file, err = oss.WithContext(storage).GetCtx(ctx, path)
+		return err
+	})
+	return file, err
+}
+
+// GetStream get file as stream
+func (failover *Failover) GetStream(path string) (io.ReadCloser, error) {
+	return failover.GetStreamCtx(context.Background(), path)
+}
+
+// GetStreamCtx get file as stream
+func (failover *Failover) GetStreamCtx(ctx context.Context, path string) (stream io.ReadCloser, err error) {
+	err = failover.do(nil, func(storage oss.StorageInterface) (err error) {
+		stream, err = oss.WithContext(storage).GetStreamCtx(ctx, path)
+		return err
+	})
+	return stream, err
+}
+
+// GetRange get length bytes of object starting at offset
+func (failover *Failover) GetRange(path string, offset, length int64) (stream io.ReadCloser, err error) {
+	err = failover.do(nil, func(storage oss.StorageInterface) (err error) {
+		stream, err = oss.GetRange(storage, path, offset, length)
+		return err
+	})
+	return stream, err
+}
+
+// Put store a reader into given path, it is failed over only if reader is io.Seeker
+func (failover *Failover) Put(path string, reader io.Reader) (*oss.Object, error) {
+	return failover.PutCtx(context.Background(), path, reader)
+}
+
+// PutCtx store a reader into given path, it is failed over only if reader is io.Seeker
+func (failover *Failover) PutCtx(ctx context.Context, path string, reader io.Reader) (object *oss.Object, err error) {
+	err = failover.do(rewind(reader), func(storage oss.StorageInterface) (err error) {
+		object, err = oss.WithContext(storage).PutCtx(ctx, path, reader)
+		return err
+	})
+	return failover.own(object), err
+}
+
+// PutWithOptions store a reader into given path with options, it is failed over only if reader is io.Seeker
+func (failover *Failover) PutWithOptions(path string, reader io.Reader, options *oss.PutOptions) (object *oss.Object, err error) {
+	err = failover.do(rewind(reader), func(storage oss.StorageInterface) (err error) {
+		object, err = oss.PutWithOptions(storage, path, reader, options)
+		return err
+	})
+	return failover.own(object), err
+}
+
+// Stat get object's metadata
+func (failover *Failover) Stat(path string) (object *oss.Object, err error) {
+	err = failover.do(nil, func(storage oss.StorageInterface) (err error) {
+		object, err = oss.Stat(storage, path)
+		return err
+	})
+	return failover.own(object), err
+}
+
+// Delete delete object
+func (failover *Failover) Delete(path string) error {
+	return failover.DeleteCtx(context.Background(), path)
+}
+
+// DeleteCtx delete object
+func (failover *Failover) DeleteCtx(ctx context.Context, path string) error {
+	return failover.do(nil, func(storage oss.StorageInterface) error {
+		return oss.WithContext(storage).DeleteCtx(ctx, path)
+	})
+}
+
+// DeleteMany delete objects at paths
+func (failover *Failover) DeleteMany(paths []string) (errs map[string]error) {
+	failover.do(nil, func(storage oss.StorageInterface) error {
+		errs = oss.DeleteMany(storage, paths)
+		for _, err := range errs {
+			if failover.config.IsFailure(err) {
+				return err
+			}
+		}
+		return nil
+	})
+	return errs
+}
+
+// Copy copy object from src to dst
+func (failover *Failover) Copy(src, dst string) error {
+	return failover.do(nil, func(storage oss.StorageInterface) error {
+		return oss.Copy(storage, src, dst)
+	})
+}
+
+// Move move object from src to dst
+func (failover *Failover) Move(src, dst string) error {
+	return failover.do(nil, func(storage oss.StorageInterface) error {
+		return oss.Move(storage, src, dst)
+	})
+}
+
+// List list all objects under current path
+func (failover *Failover) List(path string) ([]*oss.Object, error) {
+	return failover.ListCtx(context.Background(), path)
+}
+
+// ListCtx list all objects under current path
+func (failover *Failover) ListCtx(ctx context.Context, path string) (objects []*oss.Object, err error) {
+	err = failover.do(nil, func(storage oss.StorageInterface) (err error) {
+		objects, err = oss.WithContext(storage).ListCtx(ctx, path)
+		return err
+	})
+	for _, object := range objects {
+		failover.own(object)
+	}
+	return objects, err
+}
+
+// ListPage list a page of objects
+func (failover *Failover) ListPage(options oss.ListOptions) (result *oss.ListResult, err error) {
+	err = failover.do(nil, func(storage oss.StorageInterface) (err error) {
+		result, err = oss.ListPage(storage, options)
+		return err
+	})
+	if result != nil {
+		for _, object := range result.Objects {
+			failover.own(object)
+		}
+	}
+	return result, err
+}
+
+// GetEndpoint get endpoint of the first healthy storage
+func (failover *Failover) GetEndpoint() string {
+	for _, b := range failover.storages {
+		b.mutex.Lock()
+		healthy := b.healthy
+		b.mutex.Unlock()
+
+		if healthy {
+			return b.storage.GetEndpoint()
+		}
+	}
+
+	if len(failover.storages) > 0 {
+		return failover.storages[0].storage.GetEndpoint()
+	}
+	return ""
+}
+
+// GetURL get URL
+func (failover *Failover) GetURL(path string) (string, error) {
+	return failover.GetURLCtx(context.Background(), path)
+}
+
+// GetURLCtx get URL
+func (failover *Failover) GetURLCtx(ctx context.Context, path string) (url string, err error) {
+	err = failover.do(nil, func(storage oss.StorageInterface) (err error) {
+		url, err = oss.WithContext(storage).GetURLCtx(ctx, path)
+		return err
+	})
+	return url, err
+}
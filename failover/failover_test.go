@@ -0,0 +1,119 @@
+package failover
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qor/oss"
+	"github.com/qor/oss/memory"
+	"github.com/qor/oss/tests"
+)
+
+var errUnavailable = errors.New("unavailable")
+
+func unavailable(op, path string) error {
+	return errUnavailable
+}
+
+func TestAll(t *testing.T) {
+	failover := New(Config{}, memory.New(), memory.New())
+	defer failover.Close()
+
+	tests.TestAll(failover, t)
+}
+
+func TestFailover(t *testing.T) {
+	primary, secondary := memory.New(), memory.New()
+	failover := New(Config{MinRequests: 2, ProbeInterval: time.Hour}, primary, secondary)
+	defer failover.Close()
+
+	primary.Failure = unavailable
+	if _, err := failover.Put("/sample.txt", strings.NewReader("sample")); err != nil {
+		t.Fatalf("Put should fail over to secondary, but got %v", err)
+	}
+	if exists, _ := oss.Exists(secondary, "/sample.txt"); !exists {
+		t.Errorf("Sample file should be put to secondary")
+	}
+
+	// not failed over, nor counted as failure
+	primary.Failure = nil
+	if _, err := failover.Stat("/missing.txt"); !errors.Is(err, oss.ErrNotExist) {
+		t.Errorf("Missing object should fail with oss.ErrNotExist, but got %v", err)
+	}
+
+	if health := failover.Health()[0]; !health.Healthy || health.Requests != 2 || health.FailureRate != 0.5 || health.LastError == "" {
+		t.Errorf("Primary should be healthy with failure rate 0.5, but got %+v", health)
+	}
+
+	primary.Failure = unavailable
+	failover.Stat("/sample.txt")
+	if health := failover.Health()[0]; health.Healthy {
+		t.Errorf("Primary should be unhealthy once failure rate exceeds threshold, but got %+v", health)
+	}
+
+	// unhealthy primary is skipped
+	primary.Failure = nil
+	if object, err := failover.Stat("/sample.txt"); err != nil || object.StorageInterface != failover {
+		t.Errorf("Stat should be routed to secondary, but got %v, %v", object, err)
+	}
+
+	failover.Probe()
+	if health := failover.Health()[0]; !health.Healthy || health.Requests != 0 {
+		t.Errorf("Primary should be healthy once probed, but got %+v", health)
+	}
+	if _, err := failover.Stat("/sample.txt"); !errors.Is(err, oss.ErrNotExist) {
+		t.Errorf("Stat should be routed to recovered primary, but got %v", err)
+	}
+}
+
+func TestNonSeekableBody(t *testing.T) {
+	primary, secondary := memory.New(), memory.New()
+	failover := New(Config{}, primary, secondary)
+	defer failover.Close()
+
+	primary.Failure = unavailable
+	reader := struct{ io.Reader }{strings.NewReader("sample")}
+	if _, err := failover.Put("/sample.txt", reader); !errors.Is(err, errUnavailable) {
+		t.Errorf("Put of non-seekable body should not fail over, but got %v", err)
+	}
+}
+
+func TestAllUnhealthy(t *testing.T) {
+	primary, secondary := memory.New(), memory.New()
+	failover := New(Config{MinRequests: 1, ProbeInterval: time.Hour}, primary, secondary)
+	defer failover.Close()
+
+	primary.Failure, secondary.Failure = unavailable, unavailable
+	failover.Stat("/sample.txt")
+
+	for _, health := range failover.Health() {
+		if health.Healthy {
+			t.Errorf("All storages should be unhealthy, but got %+v", health)
+		}
+	}
+
+	secondary.Failure = nil
+	if _, err := failover.Stat("/sample.txt"); !errors.Is(err, oss.ErrNotExist) {
+		t.Errorf("All storages should be tried if none is healthy, but got %v", err)
+	}
+}
+
+func TestProbeInBackground(t *testing.T) {
+	primary := memory.New()
+	failover := New(Config{MinRequests: 1, ProbeInterval: 10 * time.Millisecond}, primary, memory.New())
+	defer failover.Close()
+
+	primary.Failure = unavailable
+	failover.Stat("/sample.txt")
+	primary.Failure = nil
+
+	for i := 0; i < 100 && !failover.Health()[0].Healthy; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if health := failover.Health()[0]; !health.Healthy {
+		t.Errorf("Recovered storage should be probed in background, but got %+v", health)
+	}
+}
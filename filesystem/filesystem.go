@@ -1,18 +1,47 @@
 package filesystem
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"math/rand"
+	"mime"
+	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/qor/oss"
 )
 
+var (
+	_ oss.ContextStorage = (*FileSystem)(nil)
+	_ oss.Stater         = (*FileSystem)(nil)
+	_ oss.PageLister     = (*FileSystem)(nil)
+	_ oss.OptionsPutter  = (*FileSystem)(nil)
+	_ oss.Copier         = (*FileSystem)(nil)
+	_ oss.Mover          = (*FileSystem)(nil)
+	_ oss.RangeGetter    = (*FileSystem)(nil)
+)
+
 // FileSystem file system storage
 type FileSystem struct {
 	Base string
+	// Sync fsync written files and their directories before Put returns, so they survive crashes, slower but durable
+	Sync bool
+	// Metadata store PutOptions' content type, content disposition, cache control, content encoding and user metadata
+	// in extended attributes, or in ".meta.json" sidecar files if not supported, they are returned by Stat and List
+	Metadata bool
+	// Endpoint base URL of files served by Handler, e.g. "https://example.com/files", GetURL returns paths as is if not set
+	Endpoint string
+	// SecretKey key to sign URLs with HMAC-SHA256, GetURL returns signed URLs expiring after URLExpires if set,
+	// and Handler only serves signed URLs
+	SecretKey string
+	// URLExpires how long URLs signed by GetURL are valid, 1 hour if not set
+	URLExpires time.Duration
 }
 
 // New initialize FileSystem storage
@@ -24,86 +53,474 @@ func New(base string) *FileSystem {
 	return &FileSystem{Base: absbase}
 }
 
-// GetFullPath get full path from absolute/relative path
-func (fileSystem FileSystem) GetFullPath(path string) string {
-	fullpath := path
-	if !strings.HasPrefix(path, fileSystem.Base) {
-		fullpath, _ = filepath.Abs(filepath.Join(fileSystem.Base, path))
+func init() {
+	oss.Register("file", func(u *url.URL) (oss.StorageInterface, error) {
+		return NewFromURL(u)
+	})
+}
+
+// NewFromURL initialize FileSystem storage from URL like file:///var/data, relative directories could be given as file://./data
+func NewFromURL(u *url.URL) (*FileSystem, error) {
+	base := u.Host + u.Path
+	if base == "" {
+		return nil, fmt.Errorf("filesystem: no directory given in URL %v", u)
 	}
-	return fullpath
+
+	absbase, err := filepath.Abs(base)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSystem{Base: absbase}, nil
 }
 
 // Get receive file with given path
 func (fileSystem FileSystem) Get(path string) (*os.File, error) {
-	return os.Open(fileSystem.GetFullPath(path))
+	return fileSystem.GetCtx(context.Background(), path)
+}
+
+// GetCtx receive file with given path
+func (fileSystem FileSystem) GetCtx(ctx context.Context, path string) (*os.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	fullpath, err := fileSystem.resolve("get", path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(fullpath)
+	if err != nil {
+		return nil, oss.NewError("get", path, 0, err)
+	}
+	return file, nil
 }
 
 // GetStream get file as stream
 func (fileSystem FileSystem) GetStream(path string) (io.ReadCloser, error) {
-	return os.Open(fileSystem.GetFullPath(path))
+	return fileSystem.GetStreamCtx(context.Background(), path)
+}
+
+// GetStreamCtx get file as stream, reading fails once ctx is done
+func (fileSystem FileSystem) GetStreamCtx(ctx context.Context, path string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	fullpath, err := fileSystem.resolve("get", path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(fullpath)
+	if err != nil {
+		return nil, oss.NewError("get", path, 0, err)
+	}
+	return oss.ContextReadCloser(ctx, file), nil
+}
+
+// GetRange get length bytes of file starting at offset
+func (fileSystem FileSystem) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	fullpath, err := fileSystem.resolve("get", path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(fullpath)
+	if err != nil {
+		return nil, oss.NewError("get", path, 0, err)
+	}
+
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, oss.NewError("get", path, 0, err)
+	}
+
+	if length < 0 {
+		return file, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, length), file}, nil
 }
 
 // Put store a reader into given path
 func (fileSystem FileSystem) Put(path string, reader io.Reader) (*oss.Object, error) {
-	var (
-		fullpath = fileSystem.GetFullPath(path)
-		err      = os.MkdirAll(filepath.Dir(fullpath), os.ModePerm)
-	)
+	return fileSystem.PutCtx(context.Background(), path, reader)
+}
+
+// PutCtx store a reader into given path, copying stops once ctx is done
+func (fileSystem FileSystem) PutCtx(ctx context.Context, path string, reader io.Reader) (*oss.Object, error) {
+	return fileSystem.put(ctx, path, reader, nil)
+}
 
+// PutWithOptions store a reader into given path, with Metadata, content type, content disposition, cache control,
+// content encoding and user metadata are stored, otherwise file system only stores file content so all options are ignored
+func (fileSystem FileSystem) PutWithOptions(path string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	return fileSystem.put(context.Background(), path, reader, newMetadata(options))
+}
+
+func (fileSystem FileSystem) put(ctx context.Context, path string, reader io.Reader, meta *metadata) (*oss.Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	fullpath, err := fileSystem.resolveWrite("put", path)
 	if err != nil {
 		return nil, err
 	}
 
-	dst, err := os.Create(fullpath)
+	checksum := oss.NewChecksumReader(reader)
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		seeker.Seek(0, 0)
+	}
 
+	writer := fileSystem.metadataWriter(fullpath, meta)
+	info, err := fileSystem.writeFile(fullpath, oss.ContextReader(ctx, checksum), writer.beforeRename)
 	if err == nil {
-		if seeker, ok := reader.(io.ReadSeeker); ok {
-			seeker.Seek(0, 0)
+		err = writer.afterRename()
+	}
+	if err == nil {
+		object := fileSystem.newObject(path, fullpath, info)
+		object.Checksum = checksum.Checksum()
+		return object, nil
+	}
+
+	return &oss.Object{Path: path, Name: filepath.Base(path), StorageInterface: fileSystem}, oss.NewError("put", path, 0, err)
+}
+
+// writeFile write reader into a temporary file in fullpath's directory and rename it to fullpath, so readers never
+// see partially written files, and failed writes leave the existing file untouched. With Sync, the file and its
+// directory are fsynced so the file survives crashes once written. beforeRename is called with the temporary file's
+// path after it is written, unless it is nil
+func (fileSystem FileSystem) writeFile(fullpath string, reader io.Reader, beforeRename func(tmp string) error) (info os.FileInfo, err error) {
+	dir := filepath.Dir(fullpath)
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	tmp, err := createTemp(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = io.Copy(tmp, reader); err != nil {
+		return nil, err
+	}
+
+	// keep mode of the replaced file
+	if info, statErr := os.Stat(fullpath); statErr == nil {
+		if err = tmp.Chmod(info.Mode().Perm()); err != nil {
+			return nil, err
 		}
-		_, err = io.Copy(dst, reader)
 	}
 
-	return &oss.Object{Path: path, Name: filepath.Base(path), StorageInterface: fileSystem}, err
+	if fileSystem.Sync {
+		if err = tmp.Sync(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	if beforeRename != nil {
+		if err = beforeRename(tmp.Name()); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = os.Rename(tmp.Name(), fullpath); err != nil {
+		return nil, err
+	}
+
+	if fileSystem.Sync {
+		if err = syncDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	return os.Stat(fullpath)
+}
+
+// tempPrefix prefix of temporary files being written, they are hidden from List
+const tempPrefix = ".oss-tmp-"
+
+// createTemp create a temporary file in dir like os.Create, so its mode respects umask unlike ioutil.TempFile's 0600
+func createTemp(dir string) (*os.File, error) {
+	for i := 0; i < 10000; i++ {
+		name := filepath.Join(dir, tempPrefix+strconv.FormatUint(uint64(rand.Uint32()), 36))
+		file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if !os.IsExist(err) {
+			return file, err
+		}
+	}
+	return nil, fmt.Errorf("failed to create temporary file in %v", dir)
+}
+
+// syncDir fsync directory dir so renames in it are durable, directories can't be synced on windows
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	err = file.Sync()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Stat get file's metadata
+func (fileSystem FileSystem) Stat(path string) (*oss.Object, error) {
+	fullpath, err := fileSystem.resolve("stat", path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(fullpath)
+	if err != nil {
+		return nil, oss.NewError("stat", path, 0, err)
+	}
+
+	if info.IsDir() {
+		return nil, &oss.Error{Op: "stat", Path: path, Kind: oss.ErrNotExist, Err: fmt.Errorf("%s is a directory", path)}
+	}
+	return fileSystem.newObject(path, fullpath, info), nil
 }
 
 // Delete delete file
 func (fileSystem FileSystem) Delete(path string) error {
-	return os.Remove(fileSystem.GetFullPath(path))
+	return fileSystem.DeleteCtx(context.Background(), path)
+}
+
+// DeleteCtx delete file
+func (fileSystem FileSystem) DeleteCtx(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fullpath, err := fileSystem.resolve("delete", path)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(fullpath); err == nil && fileSystem.Metadata {
+		err = removeSidecar(fullpath)
+	}
+	return oss.NewError("delete", path, 0, err)
+}
+
+// Copy copy file from src to dst, content is copied instead of hard linked so writing to one doesn't change the other
+func (fileSystem FileSystem) Copy(src, dst string) error {
+	srcpath, err := fileSystem.resolve("copy", src)
+	if err != nil {
+		return err
+	}
+
+	fullpath, err := fileSystem.resolveWrite("copy", dst)
+	if err != nil {
+		return err
+	}
+
+	srcFile, err := os.Open(srcpath)
+	if err != nil {
+		return oss.NewError("copy", src, 0, err)
+	}
+	defer srcFile.Close()
+
+	var meta *metadata
+	if fileSystem.Metadata {
+		if meta, err = fileSystem.loadMetadata(srcpath); err != nil {
+			return oss.NewError("copy", src, 0, err)
+		}
+	}
+
+	writer := fileSystem.metadataWriter(fullpath, meta)
+	if _, err = fileSystem.writeFile(fullpath, srcFile, writer.beforeRename); err == nil {
+		err = writer.afterRename()
+	}
+	return oss.NewError("copy", dst, 0, err)
+}
+
+// Move move file from src to dst with os.Rename
+func (fileSystem FileSystem) Move(src, dst string) error {
+	srcpath, err := fileSystem.resolve("move", src)
+	if err != nil {
+		return err
+	}
+
+	fullpath, err := fileSystem.resolveWrite("move", dst)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullpath), os.ModePerm); err != nil {
+		return oss.NewError("move", dst, 0, err)
+	}
+
+	if err = os.Rename(srcpath, fullpath); err == nil && fileSystem.Metadata {
+		// extended attributes are moved along with the file, but sidecar files need to be moved separately
+		if err = os.Rename(sidecarPath(srcpath), sidecarPath(fullpath)); os.IsNotExist(err) {
+			err = removeSidecar(fullpath)
+		}
+
+		// move the file back if its metadata can't follow, so Move either succeeds or leaves src untouched
+		if err != nil {
+			os.Rename(fullpath, srcpath)
+		}
+	}
+	return oss.NewError("move", src, 0, err)
 }
 
 // List list all objects under current path
 func (fileSystem FileSystem) List(path string) ([]*oss.Object, error) {
+	return fileSystem.ListCtx(context.Background(), path)
+}
+
+// ListCtx list all objects under current path, walking stops once ctx is done
+func (fileSystem FileSystem) ListCtx(ctx context.Context, path string) ([]*oss.Object, error) {
+	fullpath, err := fileSystem.resolve("list", path)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*oss.Object
+	walkErr := filepath.Walk(fullpath, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		if path == fullpath {
+			return nil
+		}
+
+		if err == nil && !info.IsDir() && !fileSystem.hidden(info.Name()) {
+			objects = append(objects, fileSystem.newObject(strings.TrimPrefix(path, fileSystem.Base), path, info))
+		}
+		return nil
+	})
+
+	if walkErr != nil {
+		return nil, walkErr
+	}
+	return objects, nil
+}
+
+// ListPage list a page of objects, only the prefix's directory is walked, and with delimiter "/"
+// its sub directories are returned as common prefixes instead of being walked
+func (fileSystem FileSystem) ListPage(options oss.ListOptions) (*oss.ListResult, error) {
 	var (
 		objects  []*oss.Object
-		fullpath = fileSystem.GetFullPath(path)
+		prefixes []string
+		prefix   = strings.TrimPrefix(options.Prefix, "/")
+		dirpath  = filepath.Dir("/" + prefix)
 	)
 
-	filepath.Walk(fullpath, func(path string, info os.FileInfo, err error) error {
-		if path == fullpath {
+	if strings.HasSuffix(prefix, "/") {
+		dirpath = prefix
+	}
+
+	dir, err := fileSystem.resolve("list", dirpath)
+	if err != nil {
+		return nil, err
+	}
+
+	walkErr := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == dir {
+			return nil
+		}
+
+		key := strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(path, fileSystem.Base)), "/")
+		if info.IsDir() {
+			if !strings.HasPrefix(key+"/", prefix) && !strings.HasPrefix(prefix, key+"/") {
+				return filepath.SkipDir
+			}
+			if options.Delimiter == "/" && strings.HasPrefix(key+"/", prefix) {
+				prefixes = append(prefixes, key+"/")
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
-		if err == nil && !info.IsDir() {
-			modTime := info.ModTime()
-			objects = append(objects, &oss.Object{
-				Path:             strings.TrimPrefix(path, fileSystem.Base),
-				Name:             info.Name(),
-				LastModified:     &modTime,
-				StorageInterface: fileSystem,
-			})
+		if !fileSystem.hidden(info.Name()) {
+			objects = append(objects, fileSystem.newObject("/"+key, path, info))
 		}
 		return nil
 	})
 
-	return objects, nil
+	if walkErr != nil {
+		return nil, oss.NewError("list", options.Prefix, 0, walkErr)
+	}
+	return oss.NewListResult(objects, prefixes, options), nil
+}
+
+// newObject build object from file info, the ETag is derived from modification time and size
+func (fileSystem FileSystem) newObject(path, fullpath string, info os.FileInfo) *oss.Object {
+	modTime := info.ModTime()
+	object := &oss.Object{
+		Path:             path,
+		Name:             info.Name(),
+		LastModified:     &modTime,
+		Size:             info.Size(),
+		ETag:             fmt.Sprintf("%x-%x", modTime.UnixNano(), info.Size()),
+		ContentType:      mime.TypeByExtension(filepath.Ext(path)),
+		StorageInterface: fileSystem,
+	}
+
+	if fileSystem.Metadata {
+		if meta, err := fileSystem.loadMetadata(fullpath); err == nil && meta != nil {
+			if meta.ContentType != "" {
+				object.ContentType = meta.ContentType
+			}
+			object.Metadata = meta.Metadata
+		}
+	}
+	return object
 }
 
-// GetEndpoint get endpoint, FileSystem's endpoint is /
+// GetEndpoint get endpoint, it is Endpoint, or / if not set
 func (fileSystem FileSystem) GetEndpoint() string {
+	if fileSystem.Endpoint != "" {
+		return fileSystem.Endpoint
+	}
 	return "/"
 }
 
 // GetURL get public accessible URL
 func (fileSystem FileSystem) GetURL(path string) (url string, err error) {
+	return fileSystem.GetURLCtx(context.Background(), path)
+}
+
+// GetURLCtx get public accessible URL, it is signed with SecretKey if set, or under Endpoint if set, otherwise path itself
+func (fileSystem FileSystem) GetURLCtx(ctx context.Context, path string) (url string, err error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if fileSystem.SecretKey != "" {
+		expires := fileSystem.URLExpires
+		if expires == 0 {
+			expires = time.Hour
+		}
+		return fileSystem.SignURL(path, time.Now().Add(expires))
+	}
+
+	if fileSystem.Endpoint != "" {
+		return fileSystem.endpointURL(cleanPath(path)), nil
+	}
 	return path, nil
 }
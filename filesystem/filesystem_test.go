@@ -1,8 +1,15 @@
 package filesystem
 
 import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/qor/oss"
 	"github.com/qor/oss/tests"
 )
 
@@ -10,3 +17,164 @@ func TestAll(t *testing.T) {
 	fileSystem := New("/tmp")
 	tests.TestAll(fileSystem, t)
 }
+
+func TestOpen(t *testing.T) {
+	storage, err := oss.Open("file:///tmp/oss")
+	if err != nil {
+		t.Fatalf("No error should happen when open file URL, but got %v", err)
+	}
+
+	if fileSystem, ok := storage.(*FileSystem); !ok || fileSystem.Base != "/tmp/oss" {
+		t.Errorf("Should open file system storage with base /tmp/oss, but got %#v", storage)
+	}
+
+	if _, err := oss.Open("file://"); err == nil {
+		t.Errorf("There should be an error when open file URL without directory")
+	}
+}
+
+type failingReader struct {
+	io.Reader
+}
+
+func (reader failingReader) Read(p []byte) (int, error) {
+	n, err := reader.Reader.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+func TestPutAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oss-filesystem")
+	if err != nil {
+		t.Fatalf("No error should happen when create temp dir, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, fileSystem := range []*FileSystem{New(dir), {Base: dir, Sync: true}} {
+		if _, err := fileSystem.Put("/sample.txt", strings.NewReader("sample")); err != nil {
+			t.Fatalf("No error should happen when put, but got %v", err)
+		}
+
+		if _, err := fileSystem.Put("/sample.txt", failingReader{strings.NewReader("partial")}); err == nil {
+			t.Errorf("There should be an error when put failed reader")
+		}
+
+		if content, err := ioutil.ReadFile(filepath.Join(dir, "sample.txt")); err != nil || string(content) != "sample" {
+			t.Errorf("Failed put should keep existing file, but got %v, %v", string(content), err)
+		}
+
+		if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+			t.Errorf("Temporary files should be removed after failed put, but got %v files", len(files))
+		}
+
+		object, err := fileSystem.Put("/sample.txt", strings.NewReader("replaced"))
+		if err != nil || object.Name != "sample.txt" || object.Size != 8 {
+			t.Errorf("Put should replace existing file, but got %#v, %v", object, err)
+		}
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, tempPrefix+"abc"), []byte("partial"), os.ModePerm)
+	if objects, err := New(dir).List("/"); err != nil || len(objects) != 1 {
+		t.Errorf("Temporary files should be hidden from list, but got %v, %v", objects, err)
+	}
+	if result, err := oss.ListPage(New(dir), oss.ListOptions{}); err != nil || len(result.Objects) != 1 {
+		t.Errorf("Temporary files should be hidden from list page, but got %v, %v", result, err)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oss-filesystem")
+	if err != nil {
+		t.Fatalf("No error should happen when create temp dir, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileSystem := &FileSystem{Base: dir, Metadata: true}
+	tests.TestAll(fileSystem, t)
+
+	options := &oss.PutOptions{ContentType: "application/x-sample", CacheControl: "no-cache", Metadata: map[string]string{"author": "jinzhu"}}
+	if _, err := fileSystem.PutWithOptions("/sample.txt", strings.NewReader("sample"), options); err != nil {
+		t.Fatalf("No error should happen when put with options, but got %v", err)
+	}
+
+	check := func(path string) {
+		object, err := fileSystem.Stat(path)
+		if err != nil || object.ContentType != "application/x-sample" || object.Metadata["author"] != "jinzhu" {
+			t.Errorf("Metadata of %v should be stored, but got %#v, %v", path, object, err)
+		}
+	}
+
+	check("/sample.txt")
+	if err := fileSystem.Copy("/sample.txt", "/copied.txt"); err != nil {
+		t.Errorf("No error should happen when copy, but got %v", err)
+	}
+	check("/copied.txt")
+	if err := fileSystem.Move("/copied.txt", "/moved.txt"); err != nil {
+		t.Errorf("No error should happen when move, but got %v", err)
+	}
+	check("/moved.txt")
+
+	if _, err := fileSystem.Put("/moved.txt", strings.NewReader("replaced")); err != nil {
+		t.Errorf("No error should happen when put, but got %v", err)
+	}
+	if object, err := fileSystem.Stat("/moved.txt"); err != nil || object.ContentType != "text/plain; charset=utf-8" || object.Metadata != nil {
+		t.Errorf("Metadata should be removed when file replaced, but got %#v, %v", object, err)
+	}
+
+	// sidecar files are used when extended attributes aren't supported
+	ioutil.WriteFile(filepath.Join(dir, "sidecar.txt"), []byte("sidecar"), os.ModePerm)
+	ioutil.WriteFile(filepath.Join(dir, "sidecar.txt"+sidecarSuffix), []byte(`{"content_type":"application/x-sample","metadata":{"author":"jinzhu"}}`), os.ModePerm)
+	check("/sidecar.txt")
+
+	if objects, err := fileSystem.List("/"); err != nil || len(objects) != 3 {
+		t.Errorf("Sidecar files should be hidden from list, but got %v, %v", objects, err)
+	}
+
+	if err := fileSystem.Delete("/sidecar.txt"); err != nil {
+		t.Errorf("No error should happen when delete, but got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sidecar.txt"+sidecarSuffix)); !os.IsNotExist(err) {
+		t.Errorf("Sidecar file should be deleted along with the file")
+	}
+
+	if _, err := fileSystem.Put("/sample.txt"+sidecarSuffix, strings.NewReader("sample")); !errors.Is(err, oss.ErrInvalidPath) {
+		t.Errorf("Put file with sidecar suffix should fail with ErrInvalidPath, but got %v", err)
+	}
+}
+
+func TestMetadataFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oss-filesystem")
+	if err != nil {
+		t.Fatalf("No error should happen when create temp dir, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileSystem := &FileSystem{Base: dir, Metadata: true}
+	options := &oss.PutOptions{ContentType: "application/x-sample"}
+
+	// a non-empty directory can't be replaced by a file
+	os.MkdirAll(filepath.Join(dir, "directory", "child"), os.ModePerm)
+	if _, err := fileSystem.PutWithOptions("/directory", strings.NewReader("sample"), options); err == nil {
+		t.Errorf("Put onto a non-empty directory should fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "directory"+sidecarSuffix)); !os.IsNotExist(err) {
+		t.Errorf("Sidecar file should not be written if the file isn't, but got %v", err)
+	}
+
+	if _, err := fileSystem.PutWithOptions("/sample.txt", strings.NewReader("sample"), options); err != nil {
+		t.Fatalf("No error should happen when put with options, but got %v", err)
+	}
+
+	os.MkdirAll(filepath.Join(dir, "moved.txt"+sidecarSuffix, "child"), os.ModePerm)
+	if err := fileSystem.Move("/sample.txt", "/moved.txt"); err == nil {
+		t.Errorf("Move should fail if metadata can't be moved")
+	}
+	if object, err := fileSystem.Stat("/sample.txt"); err != nil || object.ContentType != "application/x-sample" {
+		t.Errorf("Failed move should leave the file and its metadata untouched, but got %#v, %v", object, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "moved.txt")); !os.IsNotExist(err) {
+		t.Errorf("Failed move should not leave the file at destination, but got %v", err)
+	}
+}
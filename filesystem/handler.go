@@ -0,0 +1,156 @@
+package filesystem
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qor/oss"
+)
+
+// SignURL get URL of path signed with SecretKey, it is valid until expires, serve it with Handler
+func (fileSystem FileSystem) SignURL(path string, expires time.Time) (string, error) {
+	if fileSystem.SecretKey == "" {
+		return "", oss.NewError("url", path, 0, errors.New("filesystem: no SecretKey to sign URL"))
+	}
+
+	var (
+		cleaned = cleanPath(path)
+		query   = url.Values{}
+	)
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", fileSystem.signature(cleaned, expires.Unix()))
+	return fileSystem.endpointURL(cleaned) + "?" + query.Encode(), nil
+}
+
+// endpointURL get URL of cleaned path under Endpoint
+func (fileSystem FileSystem) endpointURL(cleaned string) string {
+	return strings.TrimSuffix(fileSystem.Endpoint, "/") + (&url.URL{Path: cleaned}).EscapedPath()
+}
+
+// signature sign cleaned path and its expiration time with HMAC-SHA256
+func (fileSystem FileSystem) signature(cleaned string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(fileSystem.SecretKey))
+	fmt.Fprintf(mac, "%s\n%d", cleaned, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify verify signature and expiration time of cleaned path in query
+func (fileSystem FileSystem) verify(cleaned string, query url.Values) error {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return &oss.Error{Op: "get", Path: cleaned, Kind: oss.ErrPermission, Err: errors.New("filesystem: URL isn't signed")}
+	}
+
+	if !hmac.Equal([]byte(query.Get("signature")), []byte(fileSystem.signature(cleaned, expires))) {
+		return &oss.Error{Op: "get", Path: cleaned, Kind: oss.ErrPermission, Err: errors.New("filesystem: invalid URL signature")}
+	}
+
+	if time.Now().Unix() > expires {
+		return &oss.Error{Op: "get", Path: cleaned, Kind: oss.ErrPermission, Err: errors.New("filesystem: URL expired")}
+	}
+	return nil
+}
+
+func cleanPath(p string) string {
+	return path.Clean("/" + filepath.ToSlash(p))
+}
+
+// Handler serve files of FileSystem over HTTP with Range, ETag, Last-Modified and Content-Type support, like a private
+// S3 bucket. Request paths are relative to the path of FileSystem's Endpoint, and with SecretKey, only URLs signed
+// by GetURL or SignURL are served. Mount it to Endpoint's path, e.g. http.Handle("/files/", filesystem.NewHandler(storage))
+type Handler struct {
+	FileSystem *FileSystem
+}
+
+// NewHandler initialize Handler serving files of fileSystem
+func NewHandler(fileSystem *FileSystem) *Handler {
+	return &Handler{FileSystem: fileSystem}
+}
+
+func (handler *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := handler.serve(w, req); err != nil {
+		status := oss.HTTPStatus(err)
+		http.Error(w, http.StatusText(status), status)
+	}
+}
+
+func (handler *Handler) serve(w http.ResponseWriter, req *http.Request) error {
+	var (
+		fileSystem = handler.FileSystem
+		cleaned    = cleanPath(req.URL.Path)
+	)
+
+	if endpoint, err := url.Parse(fileSystem.Endpoint); err == nil && endpoint.Path != "" {
+		if prefix := strings.TrimSuffix(endpoint.Path, "/"); strings.HasPrefix(cleaned, prefix+"/") {
+			cleaned = strings.TrimPrefix(cleaned, prefix)
+		}
+	}
+
+	if fileSystem.SecretKey != "" {
+		if err := fileSystem.verify(cleaned, req.URL.Query()); err != nil {
+			return err
+		}
+	}
+
+	if fileSystem.hidden(path.Base(cleaned)) {
+		return &oss.Error{Op: "get", Path: cleaned, Kind: oss.ErrNotExist, Err: os.ErrNotExist}
+	}
+
+	fullpath, err := fileSystem.resolve("get", cleaned)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(fullpath)
+	if err != nil {
+		return oss.NewError("get", cleaned, 0, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return oss.NewError("get", cleaned, 0, err)
+	} else if info.IsDir() {
+		return &oss.Error{Op: "get", Path: cleaned, Kind: oss.ErrNotExist, Err: fmt.Errorf("%s is a directory", cleaned)}
+	}
+
+	object := fileSystem.newObject(cleaned, fullpath, info)
+	w.Header().Set("ETag", strconv.Quote(object.ETag))
+	if object.ContentType != "" {
+		w.Header().Set("Content-Type", object.ContentType)
+	}
+
+	if fileSystem.Metadata {
+		if meta, err := fileSystem.loadMetadata(fullpath); err == nil && meta != nil {
+			for key, value := range map[string]string{
+				"Cache-Control":       meta.CacheControl,
+				"Content-Disposition": meta.ContentDisposition,
+				"Content-Encoding":    meta.ContentEncoding,
+			} {
+				if value != "" {
+					w.Header().Set(key, value)
+				}
+			}
+		}
+	}
+
+	http.ServeContent(w, req, info.Name(), info.ModTime(), file)
+	return nil
+}
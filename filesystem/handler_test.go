@@ -0,0 +1,109 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qor/oss"
+)
+
+func TestHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oss-filesystem")
+	if err != nil {
+		t.Fatalf("No error should happen when create temp dir, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		fileSystem = &FileSystem{Base: dir, SecretKey: "secret", Metadata: true}
+		mux        = http.NewServeMux()
+		server     = httptest.NewServer(mux)
+	)
+	defer server.Close()
+
+	mux.Handle("/files/", NewHandler(fileSystem))
+	fileSystem.Endpoint = server.URL + "/files"
+
+	options := &oss.PutOptions{ContentType: "application/x-sample", CacheControl: "max-age=60"}
+	object, err := fileSystem.PutWithOptions("/sample dir/sample.txt", strings.NewReader("sample"), options)
+	if err != nil {
+		t.Fatalf("No error should happen when put, but got %v", err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, tempPrefix+"abc"), []byte("partial"), os.ModePerm)
+
+	get := func(url string, header http.Header) (*http.Response, string) {
+		req, _ := http.NewRequest("GET", url, nil)
+		for key := range header {
+			req.Header.Set(key, header.Get(key))
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("No error should happen when request %v, but got %v", url, err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	url, err := fileSystem.GetURL("/sample dir/sample.txt")
+	if err != nil || !strings.HasPrefix(url, server.URL+"/files/sample%20dir/sample.txt?") {
+		t.Fatalf("URL should be signed under endpoint, but got %v, %v", url, err)
+	}
+
+	resp, body := get(url, nil)
+	if resp.StatusCode != http.StatusOK || body != "sample" {
+		t.Errorf("Signed URL should be served, but got %v, %v", resp.Status, body)
+	}
+	if resp.Header.Get("ETag") != `"`+object.ETag+`"` || resp.Header.Get("Last-Modified") == "" {
+		t.Errorf("ETag and Last-Modified should be set, but got %v", resp.Header)
+	}
+	if resp.Header.Get("Content-Type") != "application/x-sample" || resp.Header.Get("Cache-Control") != "max-age=60" {
+		t.Errorf("Stored metadata should be set as headers, but got %v", resp.Header)
+	}
+
+	if resp, body := get(url, http.Header{"Range": {"bytes=1-3"}}); resp.StatusCode != http.StatusPartialContent || body != "amp" {
+		t.Errorf("Range should be served, but got %v, %v", resp.Status, body)
+	}
+
+	if resp, _ := get(url, http.Header{"If-None-Match": {`"` + object.ETag + `"`}}); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("Not modified file should get 304, but got %v", resp.Status)
+	}
+
+	expired, _ := fileSystem.SignURL("/sample dir/sample.txt", time.Now().Add(-time.Minute))
+	other, _ := fileSystem.SignURL("/other.txt", time.Now().Add(time.Minute))
+	for _, url := range []string{
+		server.URL + "/files/sample%20dir/sample.txt",
+		expired,
+		strings.Replace(other, "/other.txt", "/sample%20dir/sample.txt", 1),
+		strings.Replace(url, "signature=", "signature=0", 1),
+	} {
+		if resp, _ := get(url, nil); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("URL %v should be forbidden, but got %v", url, resp.Status)
+		}
+	}
+
+	for _, path := range []string{"/missing.txt", "/sample dir", "/" + tempPrefix + "abc", "/../../sample dir/sample.txt" + sidecarSuffix} {
+		url, _ := fileSystem.SignURL(path, time.Now().Add(time.Minute))
+		if resp, _ := get(url, nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("URL %v should be not found, but got %v", url, resp.Status)
+		}
+	}
+
+	if resp, err := http.Post(url, "text/plain", strings.NewReader("sample")); err != nil || resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Post should not be allowed, but got %v, %v", resp, err)
+	}
+
+	fileSystem.SecretKey = ""
+	if url, err := fileSystem.GetURL("/sample dir/sample.txt"); err != nil || url != server.URL+"/files/sample%20dir/sample.txt" {
+		t.Errorf("URL should be under endpoint, but got %v, %v", url, err)
+	} else if resp, body := get(url, nil); resp.StatusCode != http.StatusOK || body != "sample" {
+		t.Errorf("Files should be served without signature if no SecretKey, but got %v, %v", resp.Status, body)
+	}
+}
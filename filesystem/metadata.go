@@ -0,0 +1,135 @@
+package filesystem
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/qor/oss"
+)
+
+const (
+	// xattrName name of the extended attribute storing metadata
+	xattrName = "user.oss.metadata"
+	// sidecarSuffix suffix of sidecar files storing metadata if extended attributes aren't supported, they are hidden from List
+	sidecarSuffix = ".meta.json"
+)
+
+// errXattrUnsupported returned when extended attributes aren't supported by the OS or the file system
+var errXattrUnsupported = errors.New("extended attributes are not supported")
+
+// metadata metadata stored along with files
+type metadata struct {
+	ContentType        string            `json:"content_type,omitempty"`
+	ContentDisposition string            `json:"content_disposition,omitempty"`
+	CacheControl       string            `json:"cache_control,omitempty"`
+	ContentEncoding    string            `json:"content_encoding,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+}
+
+// newMetadata get metadata to store from options, returns nil if there is nothing to store
+func newMetadata(options *oss.PutOptions) *metadata {
+	if options == nil {
+		return nil
+	}
+
+	meta := &metadata{
+		ContentType:        options.ContentType,
+		ContentDisposition: options.ContentDisposition,
+		CacheControl:       options.CacheControl,
+		ContentEncoding:    options.ContentEncoding,
+		Metadata:           options.Metadata,
+	}
+	if meta.ContentType == "" && meta.ContentDisposition == "" && meta.CacheControl == "" && meta.ContentEncoding == "" && len(meta.Metadata) == 0 {
+		return nil
+	}
+	return meta
+}
+
+// metadataWriter write metadata of file at fullpath, extended attributes are set on its temporary file in beforeRename
+// so they replace the old ones along with the content, sidecar files are written in afterRename, once the content is
+// replaced, so a failed write never leaves new metadata next to old content
+type metadataWriter struct {
+	fileSystem FileSystem
+	fullpath   string
+	meta       *metadata
+	data       []byte
+	sidecar    bool
+}
+
+// metadataWriter get writer to save meta of file at fullpath, it does nothing without Metadata
+func (fileSystem FileSystem) metadataWriter(fullpath string, meta *metadata) *metadataWriter {
+	return &metadataWriter{fileSystem: fileSystem, fullpath: fullpath, meta: meta}
+}
+
+// beforeRename set metadata as extended attributes of the temporary file tmp, falls back to a sidecar file if they aren't supported
+func (writer *metadataWriter) beforeRename(tmp string) (err error) {
+	if !writer.fileSystem.Metadata || writer.meta == nil {
+		return nil
+	}
+
+	if writer.data, err = json.Marshal(writer.meta); err != nil {
+		return err
+	}
+
+	if err = setXattr(tmp, xattrName, writer.data); err == errXattrUnsupported {
+		writer.sidecar = true
+		return nil
+	}
+	return err
+}
+
+// afterRename write the sidecar file with its own temporary file if needed, stale sidecar file is removed otherwise
+func (writer *metadataWriter) afterRename() error {
+	if !writer.fileSystem.Metadata {
+		return nil
+	}
+
+	if writer.sidecar {
+		if _, err := writer.fileSystem.writeFile(sidecarPath(writer.fullpath), bytes.NewReader(writer.data), nil); err != nil {
+			// don't leave old metadata next to new content
+			removeSidecar(writer.fullpath)
+			return err
+		}
+		return nil
+	}
+	return removeSidecar(writer.fullpath)
+}
+
+func sidecarPath(fullpath string) string {
+	return fullpath + sidecarSuffix
+}
+
+// hidden check if file name is hidden from List, it is a temporary file or a sidecar file
+func (fileSystem FileSystem) hidden(name string) bool {
+	return strings.HasPrefix(name, tempPrefix) || (fileSystem.Metadata && strings.HasSuffix(name, sidecarSuffix))
+}
+
+// loadMetadata load metadata of file at fullpath from its extended attributes or sidecar file, returns nil if not found
+func (fileSystem FileSystem) loadMetadata(fullpath string) (*metadata, error) {
+	data, err := getXattr(fullpath, xattrName)
+	if err == errXattrUnsupported || os.IsNotExist(err) {
+		if data, err = ioutil.ReadFile(sidecarPath(fullpath)); os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var meta metadata
+	if err = json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+func removeSidecar(fullpath string) error {
+	if err := os.Remove(sidecarPath(fullpath)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
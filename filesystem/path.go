@@ -0,0 +1,112 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/qor/oss"
+)
+
+// GetFullPath get full path from absolute/relative path, paths already under Base are returned cleaned,
+// others are joined onto Base. It is lexical only, use ResolvePath to make sure the path stays inside Base
+func (fileSystem FileSystem) GetFullPath(path string) string {
+	if rel, ok := fileSystem.trimBase(path); ok {
+		path = rel
+	}
+	fullpath, _ := filepath.Abs(filepath.Join(fileSystem.Base, path))
+	return fullpath
+}
+
+// ResolvePath get full path of path like GetFullPath, but returns an error of kind oss.ErrInvalidPath
+// if it resolves outside of Base, either through ".." segments or symlinks pointing outside of Base.
+// Symlinks inside Base are allowed, dangling symlinks are refused as their targets can't be checked
+func (fileSystem FileSystem) ResolvePath(path string) (string, error) {
+	fullpath := fileSystem.GetFullPath(path)
+	base, err := filepath.Abs(fileSystem.Base)
+	if err != nil {
+		return "", oss.NewError("resolve", path, 0, err)
+	}
+
+	if !within(base, fullpath) {
+		return "", invalidPath(path, "resolves outside of %v", base)
+	}
+
+	realBase, err := filepath.EvalSymlinks(base)
+	if os.IsNotExist(err) {
+		// nothing exists under Base yet, so there is no symlink to follow
+		return fullpath, nil
+	} else if err != nil {
+		return "", oss.NewError("resolve", path, 0, err)
+	}
+
+	// resolve symlinks of the longest existing part of the path, the rest will be created as regular files and directories
+	existing, rest := fullpath, ""
+	for existing != base {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return "", oss.NewError("resolve", path, 0, err)
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = filepath.Dir(existing)
+	}
+
+	resolved, err := filepath.EvalSymlinks(existing)
+	if os.IsNotExist(err) {
+		return "", invalidPath(path, "%v is a dangling symlink", existing)
+	} else if err != nil {
+		return "", oss.NewError("resolve", path, 0, err)
+	}
+
+	if !within(realBase, filepath.Join(resolved, rest)) {
+		return "", invalidPath(path, "symlink %v points outside of %v", existing, base)
+	}
+	return fullpath, nil
+}
+
+// resolve resolve path with ResolvePath for operation op
+func (fileSystem FileSystem) resolve(op, path string) (string, error) {
+	fullpath, err := fileSystem.ResolvePath(path)
+	if ossErr, ok := err.(*oss.Error); ok {
+		opErr := *ossErr
+		opErr.Op = op
+		return "", &opErr
+	}
+	return fullpath, err
+}
+
+// resolveWrite resolve path to write for operation op, paths of sidecar files are reserved with Metadata
+func (fileSystem FileSystem) resolveWrite(op, path string) (string, error) {
+	if fileSystem.Metadata && strings.HasSuffix(path, sidecarSuffix) {
+		return "", &oss.Error{Op: op, Path: path, Kind: oss.ErrInvalidPath, Err: fmt.Errorf("%w: %v is reserved for metadata", oss.ErrInvalidPath, sidecarSuffix)}
+	}
+	return fileSystem.resolve(op, path)
+}
+
+// trimBase trim Base from path if path is Base or under it, a path only sharing the prefix string
+// with Base, like /var/data-other of /var/data, isn't under it
+func (fileSystem FileSystem) trimBase(path string) (string, bool) {
+	base := filepath.Clean(fileSystem.Base)
+	if path == base {
+		return "", true
+	}
+	if base == string(filepath.Separator) {
+		return path, strings.HasPrefix(path, base)
+	}
+	if strings.HasPrefix(path, base+string(filepath.Separator)) {
+		return strings.TrimPrefix(path, base), true
+	}
+	return path, false
+}
+
+// within check if path is dir or under it, both should be absolute and cleaned
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func invalidPath(path string, format string, args ...interface{}) error {
+	return &oss.Error{Op: "resolve", Path: path, Kind: oss.ErrInvalidPath, Err: fmt.Errorf("%w: %s", oss.ErrInvalidPath, fmt.Sprintf(format, args...))}
+}
@@ -0,0 +1,29 @@
+//go:build go1.18
+// +build go1.18
+
+package filesystem
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// FuzzResolvePath check resolved paths never leave base, run with go test -fuzz FuzzResolvePath ./filesystem,
+// TestResolvePathCorpus checks its seed corpus on toolchains without fuzzing
+func FuzzResolvePath(f *testing.F) {
+	for _, path := range resolvePathCorpus {
+		f.Add(path)
+	}
+
+	fileSystem, cleanup := newSandbox(f)
+	defer cleanup()
+
+	realBase, err := filepath.EvalSymlinks(fileSystem.Base)
+	if err != nil {
+		f.Fatalf("No error should happen when resolve base, but got %v", err)
+	}
+
+	f.Fuzz(func(t *testing.T, path string) {
+		checkResolvePath(t, fileSystem, realBase, path)
+	})
+}
@@ -0,0 +1,187 @@
+package filesystem
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qor/oss"
+)
+
+// newSandbox create a file system storage in a temporary directory, with a sibling directory
+// sharing the base's name as prefix, and symlinks pointing inside and outside of the base
+func newSandbox(t testing.TB) (*FileSystem, func()) {
+	dir, err := ioutil.TempDir("", "oss-filesystem")
+	if err != nil {
+		t.Fatalf("No error should happen when create temp dir, but got %v", err)
+	}
+
+	var (
+		base  = filepath.Join(dir, "data")
+		other = filepath.Join(dir, "data-other")
+	)
+
+	for _, d := range []string{filepath.Join(base, "inside"), other} {
+		if err := os.MkdirAll(d, os.ModePerm); err != nil {
+			t.Fatalf("No error should happen when create dir, but got %v", err)
+		}
+	}
+	ioutil.WriteFile(filepath.Join(base, "inside", "sample.txt"), []byte("sample"), os.ModePerm)
+	ioutil.WriteFile(filepath.Join(other, "secret.txt"), []byte("secret"), os.ModePerm)
+
+	for link, target := range map[string]string{
+		"outside":      other,
+		"outside.txt":  filepath.Join(other, "secret.txt"),
+		"relative":     "../data-other",
+		"linked":       "inside",
+		"dangling.txt": filepath.Join(other, "missing.txt"),
+	} {
+		if err := os.Symlink(target, filepath.Join(base, link)); err != nil {
+			t.Skipf("Symlinks are not supported, got %v", err)
+		}
+	}
+
+	return New(base), func() { os.RemoveAll(dir) }
+}
+
+func TestResolvePath(t *testing.T) {
+	fileSystem, cleanup := newSandbox(t)
+	defer cleanup()
+
+	for _, path := range []string{
+		"/inside/sample.txt",
+		"inside/sample.txt",
+		"/inside/../inside/sample.txt",
+		"/linked/sample.txt",
+		"/linked/new/file.txt",
+		"/missing/dir/file.txt",
+		"/",
+		fileSystem.Base + "/inside/sample.txt",
+	} {
+		fullpath, err := fileSystem.ResolvePath(path)
+		if err != nil {
+			t.Errorf("No error should happen when resolve %v, but got %v", path, err)
+		} else if !within(fileSystem.Base, fullpath) {
+			t.Errorf("Resolved path of %v should be inside of base, but got %v", path, fullpath)
+		}
+	}
+
+	for _, path := range []string{
+		"../data-other/secret.txt",
+		"/../../../../etc/passwd",
+		"/inside/../../data-other/secret.txt",
+		"..",
+		"/outside/secret.txt",
+		"/outside/new.txt",
+		"/outside.txt",
+		"/relative/secret.txt",
+		"/dangling.txt",
+		fileSystem.Base + "/../data-other/secret.txt",
+	} {
+		if fullpath, err := fileSystem.ResolvePath(path); !errors.Is(err, oss.ErrInvalidPath) {
+			t.Errorf("Resolve %v should fail with ErrInvalidPath, but got %v, %v", path, fullpath, err)
+		}
+	}
+
+	sibling := filepath.Dir(fileSystem.Base) + "/data-other/secret.txt"
+	if fullpath, err := fileSystem.ResolvePath(sibling); err != nil || fullpath != filepath.Join(fileSystem.Base, sibling) {
+		t.Errorf("Path sharing base as prefix should be joined onto base, but got %v, %v", fullpath, err)
+	}
+}
+
+func TestPathTraversal(t *testing.T) {
+	fileSystem, cleanup := newSandbox(t)
+	defer cleanup()
+
+	if _, err := fileSystem.Get("/outside/secret.txt"); !errors.Is(err, oss.ErrInvalidPath) {
+		t.Errorf("Get file through symlink outside should fail with ErrInvalidPath, but got %v", err)
+	} else if ossErr, ok := err.(*oss.Error); !ok || ossErr.Op != "get" {
+		t.Errorf("Error should be of get operation, but got %#v", err)
+	}
+
+	if _, err := fileSystem.Put("/../data-other/new.txt", strings.NewReader("sample")); !errors.Is(err, oss.ErrInvalidPath) {
+		t.Errorf("Put file outside should fail with ErrInvalidPath, but got %v", err)
+	}
+	if _, err := fileSystem.Put("/dangling.txt", strings.NewReader("sample")); !errors.Is(err, oss.ErrInvalidPath) {
+		t.Errorf("Put file through dangling symlink should fail with ErrInvalidPath, but got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(fileSystem.Base), "data-other", "missing.txt")); !os.IsNotExist(err) {
+		t.Errorf("No file should be created outside of base")
+	}
+
+	if err := fileSystem.Delete("/outside.txt/../outside/secret.txt"); !errors.Is(err, oss.ErrInvalidPath) {
+		t.Errorf("Delete file outside should fail with ErrInvalidPath, but got %v", err)
+	}
+	if err := fileSystem.Copy("/outside.txt", "/copied.txt"); !errors.Is(err, oss.ErrInvalidPath) {
+		t.Errorf("Copy file outside should fail with ErrInvalidPath, but got %v", err)
+	}
+	if _, err := fileSystem.List("/relative"); !errors.Is(err, oss.ErrInvalidPath) {
+		t.Errorf("List directory outside should fail with ErrInvalidPath, but got %v", err)
+	}
+
+	if content, err := fileSystem.GetStream("/linked/sample.txt"); err != nil {
+		t.Errorf("Get file through symlink inside should work, but got %v", err)
+	} else {
+		data, _ := ioutil.ReadAll(content)
+		content.Close()
+		if string(data) != "sample" {
+			t.Errorf("Content should be sample, but got %v", string(data))
+		}
+	}
+}
+
+// resolvePathCorpus paths checked by TestResolvePathCorpus, they are also the seed corpus of FuzzResolvePath
+var resolvePathCorpus = []string{
+	"/inside/sample.txt",
+	"inside/../inside/sample.txt",
+	"/linked/sample.txt",
+	"../data-other/secret.txt",
+	"/../../../../etc/passwd",
+	"..",
+	"/./..//../data-other",
+	"inside/..\\..\\data-other",
+	"/outside/secret.txt",
+	"/outside.txt",
+	"/relative/secret.txt",
+	"/dangling.txt",
+	"/data-other/secret.txt",
+	"",
+	"\x00",
+}
+
+// checkResolvePath check resolved path of path never leaves base of fileSystem, whose symlinks are resolved to realBase
+func checkResolvePath(t testing.TB, fileSystem *FileSystem, realBase, path string) {
+	fullpath, err := fileSystem.ResolvePath(path)
+	if err != nil {
+		var ossErr *oss.Error
+		if !errors.As(err, &ossErr) {
+			t.Errorf("Error of resolving %q should be *oss.Error, but got %#v", path, err)
+		}
+		return
+	}
+
+	if !within(fileSystem.Base, fullpath) {
+		t.Fatalf("Resolved path of %q should be inside of base, but got %v", path, fullpath)
+	}
+
+	if resolved, err := filepath.EvalSymlinks(fullpath); err == nil && !within(realBase, resolved) {
+		t.Fatalf("Resolved path of %q should not follow symlinks outside of base, but got %v", path, resolved)
+	}
+}
+
+func TestResolvePathCorpus(t *testing.T) {
+	fileSystem, cleanup := newSandbox(t)
+	defer cleanup()
+
+	realBase, err := filepath.EvalSymlinks(fileSystem.Base)
+	if err != nil {
+		t.Fatalf("No error should happen when resolve base, but got %v", err)
+	}
+
+	for _, path := range resolvePathCorpus {
+		checkResolvePath(t, fileSystem, realBase, path)
+	}
+}
@@ -0,0 +1,45 @@
+//go:build linux
+// +build linux
+
+package filesystem
+
+import (
+	"os"
+	"syscall"
+)
+
+// getXattr get extended attribute name of path, returns an error matching os.ErrNotExist if it isn't set
+func getXattr(path, name string) ([]byte, error) {
+	for {
+		size, err := syscall.Getxattr(path, name, nil)
+		if err != nil {
+			return nil, xattrError(err)
+		}
+
+		data := make([]byte, size)
+		n, err := syscall.Getxattr(path, name, data)
+		if err == syscall.ERANGE {
+			// attribute grew since getting its size
+			continue
+		} else if err != nil {
+			return nil, xattrError(err)
+		}
+		return data[:n], nil
+	}
+}
+
+func setXattr(path, name string, data []byte) error {
+	return xattrError(syscall.Setxattr(path, name, data, 0))
+}
+
+func xattrError(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case syscall.ENOTSUP:
+		return errXattrUnsupported
+	case syscall.ENODATA:
+		return os.ErrNotExist
+	}
+	return err
+}
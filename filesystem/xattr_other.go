@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package filesystem
+
+func getXattr(path, name string) ([]byte, error) {
+	return nil, errXattrUnsupported
+}
+
+func setXattr(path, name string, data []byte) error {
+	return errXattrUnsupported
+}
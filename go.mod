@@ -11,8 +11,12 @@ require (
 	github.com/ipfs/go-ipfs v0.7.0
 	github.com/ipfs/go-ipfs-config v0.12.0
 	github.com/ipfs/go-ipfs-files v0.0.8
+	github.com/ipfs/go-ipfs-pinner v0.0.4
+	github.com/ipfs/go-ipld-format v0.2.0
+	github.com/ipfs/go-mfs v0.1.2
 	github.com/ipfs/interface-go-ipfs-core v0.4.0
 	github.com/jinzhu/configor v1.2.1
+	github.com/klauspost/compress v1.11.13
 	github.com/libp2p/go-libp2p-core v0.6.1
 	github.com/libp2p/go-libp2p-peerstore v0.2.6 // indirect
 	github.com/libp2p/go-sockaddr v0.1.0 // indirect
@@ -3,23 +3,31 @@ package ipfs
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
+	gopath "path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	config "github.com/ipfs/go-ipfs-config"
 	files "github.com/ipfs/go-ipfs-files"
+	pin "github.com/ipfs/go-ipfs-pinner"
 	"github.com/ipfs/go-ipfs/core"
 	"github.com/ipfs/go-ipfs/core/coreapi"
 	"github.com/ipfs/go-ipfs/core/node/libp2p"
 	"github.com/ipfs/go-ipfs/plugin/loader"
 	"github.com/ipfs/go-ipfs/repo/fsrepo"
+	ipld "github.com/ipfs/go-ipld-format"
+	mfs "github.com/ipfs/go-mfs"
 	icore "github.com/ipfs/interface-go-ipfs-core"
 	ipath "github.com/ipfs/interface-go-ipfs-core/path"
 	peer "github.com/libp2p/go-libp2p-core/peer"
@@ -63,6 +71,15 @@ type Config struct {
 
 }
 
+var (
+	_ oss.ContextStorage = (*Ipfs)(nil)
+	_ oss.Stater         = (*Ipfs)(nil)
+	_ oss.OptionsPutter  = (*Ipfs)(nil)
+	_ oss.Copier         = (*Ipfs)(nil)
+	_ oss.Mover          = (*Ipfs)(nil)
+	_ oss.RangeGetter    = (*Ipfs)(nil)
+)
+
 // Ipfs provides storage interface using IPFS
 type Ipfs struct {
 	// ipfs core api
@@ -75,6 +92,47 @@ type Ipfs struct {
 	ipfsNode *core.IpfsNode
 }
 
+func init() {
+	oss.Register("ipfs", func(u *url.URL) (oss.StorageInterface, error) {
+		return NewFromURL(u)
+	})
+}
+
+// NewFromURL creates a new Ipfs instance from URL like ipfs:///var/ipfs?temp_dir=/tmp/ipfs, the URL's
+// path is the repo root path. Supported parameters are temp_dir, networking, encrypted_connections,
+// private_network, node_type and peers (comma separated multiaddrs)
+func NewFromURL(u *url.URL) (*Ipfs, error) {
+	query := u.Query()
+	cfg := &Config{
+		RootPath: u.Host + u.Path,
+		TempDir:  query.Get("temp_dir"),
+		NodeType: query.Get("node_type"),
+	}
+
+	if cfg.RootPath == "" {
+		return nil, fmt.Errorf("ipfs: no root path given in URL %v", u)
+	}
+
+	if cfg.TempDir == "" {
+		cfg.TempDir = os.TempDir()
+	}
+
+	if peers := query.Get("peers"); peers != "" {
+		cfg.Peers = strings.Split(peers, ",")
+	}
+
+	for name, value := range map[string]*bool{"networking": &cfg.Networking, "encrypted_connections": &cfg.EncryptedConnnections, "private_network": &cfg.PrivateNetwork} {
+		if query.Get(name) != "" {
+			var err error
+			if *value, err = strconv.ParseBool(query.Get(name)); err != nil {
+				return nil, fmt.Errorf("ipfs: invalid %s %q: %v", name, query.Get(name), err)
+			}
+		}
+	}
+
+	return New(cfg)
+}
+
 // New creates a new Ipfs instance
 func New(cfg *Config) (*Ipfs, error) {
 	ipfs := &Ipfs{config: cfg}
@@ -182,6 +240,11 @@ func ensureDir(path string) (err error) {
 // Get retrieves object at path and returns as a os.File instance
 // path should be ipfs cid. Caller should close file when done
 func (fs *Ipfs) Get(path string) (f *os.File, err error) {
+	return fs.GetCtx(context.Background(), path)
+}
+
+// GetCtx is Get with a context controlling the retrieval of the object
+func (fs *Ipfs) GetCtx(ctx context.Context, path string) (f *os.File, err error) {
 	// Create output filename from the CID path string
 	var fname string
 	fname, err = fs.makeFilename(path)
@@ -196,14 +259,14 @@ func (fs *Ipfs) Get(path string) (f *os.File, err error) {
 
 	p := ipath.New(path)
 	unixfs := fs.coreAPI.Unixfs()
-	node, err := unixfs.Get(context.Background(), p)
+	node, err := unixfs.Get(ctx, p)
 	if err != nil {
-		return nil, err
+		return nil, wrapError("get", path, err)
 	}
 
 	// Write content to filesystem
 	if err = files.WriteTo(node, fname); err != nil {
-		return nil, err
+		return nil, wrapError("get", path, err)
 	}
 
 	return os.Open(fname)
@@ -211,44 +274,110 @@ func (fs *Ipfs) Get(path string) (f *os.File, err error) {
 
 // GetStream provides a stream for the file at path which should be CID string
 func (fs *Ipfs) GetStream(path string) (io.ReadCloser, error) {
+	return fs.GetStreamCtx(context.Background(), path)
+}
+
+// GetStreamCtx is GetStream with a context, blocks are fetched using ctx
+// so cancelling it stops reading from the stream
+func (fs *Ipfs) GetStreamCtx(ctx context.Context, path string) (io.ReadCloser, error) {
 	p := ipath.New(path)
 	unixfs := fs.coreAPI.Unixfs()
-	node, err := unixfs.Get(context.Background(), p)
+	node, err := unixfs.Get(ctx, p)
 	if err != nil {
-		return nil, err
+		return nil, wrapError("get", path, err)
 	}
 
 	// node should be files.File
 	file, ok := node.(files.File)
 	if !ok {
-		return nil, fmt.Errorf("path is not a file: '%s'", path)
+		return nil, &oss.Error{Op: "get", Path: path, Kind: oss.ErrNotExist, Err: fmt.Errorf("path is not a file: '%s'", path)}
 	}
 
 	return file, nil
 }
 
+// GetRange provides a stream of length bytes of the file at path starting at offset,
+// the unixfs file is seeked so blocks before offset are not fetched
+func (fs *Ipfs) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	stream, err := fs.GetStream(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := stream.(files.File)
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, wrapError("get", path, err)
+	}
+
+	if length < 0 {
+		return file, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, length), file}, nil
+}
+
+// Stat returns the unixfs metadata of the file at path which should be CID string
+func (fs *Ipfs) Stat(path string) (*oss.Object, error) {
+	ctx := context.Background()
+	resolved, err := fs.coreAPI.ResolvePath(ctx, ipath.New(path))
+	if err != nil {
+		return nil, wrapError("stat", path, err)
+	}
+
+	node, err := fs.coreAPI.Unixfs().Get(ctx, resolved)
+	if err != nil {
+		return nil, wrapError("stat", path, err)
+	}
+	defer node.Close()
+
+	if _, ok := node.(files.File); !ok {
+		return nil, &oss.Error{Op: "stat", Path: path, Kind: oss.ErrNotExist, Err: fmt.Errorf("path is not a file: '%s'", path)}
+	}
+
+	size, err := node.Size()
+	if err != nil {
+		return nil, wrapError("stat", path, err)
+	}
+
+	return &oss.Object{
+		Path:             "/ipfs/" + resolved.Cid().String(),
+		Name:             resolved.Cid().String(),
+		Size:             size,
+		ETag:             resolved.Cid().String(),
+		StorageInterface: fs,
+	}, nil
+}
+
 // Put implies adding file to ipfs.  If reader is nil then assume path references
 // the file or directory to add. The file is pinned to prevent GC, when deleted the
 // pin is removed.
 func (fs *Ipfs) Put(path string, reader io.Reader) (*oss.Object, error) {
+	return fs.PutCtx(context.Background(), path, reader)
+}
+
+// PutCtx is Put with a context controlling adding and pinning the file
+func (fs *Ipfs) PutCtx(ctx context.Context, path string, reader io.Reader) (*oss.Object, error) {
 	// The ipfs file
 	var node files.Node
 	if reader == nil {
 		st, err := os.Stat(path)
 		if err != nil {
-			return nil, err
+			return nil, wrapError("put", path, err)
 		}
 		node, err = files.NewSerialFile(path, false, st)
 		if err != nil {
-			return nil, err
+			return nil, wrapError("put", path, err)
 		}
 	} else {
-		node = files.NewReaderFile(reader)
+		node = files.NewReaderFile(oss.ContextReader(ctx, reader))
 	}
 
-	res, err := fs.coreAPI.Unixfs().Add(context.Background(), node)
+	res, err := fs.coreAPI.Unixfs().Add(ctx, node)
 	if err != nil {
-		return nil, err
+		return nil, wrapError("put", path, err)
 	}
 
 	// Pin the file
@@ -256,34 +385,86 @@ func (fs *Ipfs) Put(path string, reader io.Reader) (*oss.Object, error) {
 	now := time.Now()
 
 	ipath := ipath.New(p)
-	err = fs.coreAPI.Pin().Add(context.Background(), ipath)
+	err = fs.coreAPI.Pin().Add(ctx, ipath)
+
+	// Size of the added unixfs node
+	var size int64
+	if added, err := fs.coreAPI.Unixfs().Get(ctx, res); err == nil {
+		size, _ = added.Size()
+		added.Close()
+	}
 
 	return &oss.Object{
 		Path:             p,
 		Name:             strings.Split(p, "/")[2],
 		LastModified:     &now,
+		Size:             size,
+		ETag:             res.Cid().String(),
 		StorageInterface: fs,
-	}, err
+	}, wrapError("put", path, err)
+}
+
+// PutWithOptions is Put, ipfs only stores file content so all options are ignored
+func (fs *Ipfs) PutWithOptions(path string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	return fs.Put(path, reader)
 }
 
 // Delete removes pinned path so it maybe GC. path should be the
 // CID to remove
 func (fs *Ipfs) Delete(path string) error {
+	return fs.DeleteCtx(context.Background(), path)
+}
+
+// DeleteCtx is Delete with a context controlling the unpinning
+func (fs *Ipfs) DeleteCtx(ctx context.Context, path string) error {
 	// Remoe file if on disk and unpinn
 	if fname, err := fs.makeFilename(path); err == nil {
 		os.Remove(fname)
 	}
 
 	ipath := ipath.New(path)
-	return fs.coreAPI.Pin().Rm(context.Background(), ipath)
+	return wrapError("delete", path, fs.coreAPI.Pin().Rm(ctx, ipath))
+}
+
+// Copy adds the file at ipfs path src to the node's MFS (as "ipfs files cp") at dst, e.g. /images/logo.png.
+// Content is addressed by CID, so the copy shares blocks with src and keeps them from GC after src is deleted
+func (fs *Ipfs) Copy(src, dst string) error {
+	ctx := context.Background()
+	node, err := fs.coreAPI.ResolveNode(ctx, ipath.New(src))
+	if err != nil {
+		return wrapError("copy", src, err)
+	}
+
+	dst = "/" + strings.TrimPrefix(dst, "/")
+	if err = mfs.Mkdir(fs.ipfsNode.FilesRoot, gopath.Dir(dst), mfs.MkdirOpts{Mkparents: true}); err != nil {
+		return wrapError("copy", dst, err)
+	}
+	return wrapError("copy", dst, mfs.PutNode(fs.ipfsNode.FilesRoot, dst, node))
+}
+
+// Move moves the file within the node's MFS (as "ipfs files mv"), sources under /ipfs/ are immutable,
+// so they are copied into MFS and unpinned
+func (fs *Ipfs) Move(src, dst string) error {
+	if strings.HasPrefix(src, "/ipfs/") {
+		if err := fs.Copy(src, dst); err != nil {
+			return err
+		}
+		return fs.Delete(src)
+	}
+	return wrapError("move", src, mfs.Mv(fs.ipfsNode.FilesRoot, src, "/"+strings.TrimPrefix(dst, "/")))
 }
 
 // List the files at directory path (path should be ipfs cid for directory)
 func (fs *Ipfs) List(path string) ([]*oss.Object, error) {
+	return fs.ListCtx(context.Background(), path)
+}
+
+// ListCtx is List with a context, listing stops when ctx is done
+func (fs *Ipfs) ListCtx(ctx context.Context, path string) ([]*oss.Object, error) {
 	dir := ipath.New(path)
-	entries, err := fs.coreAPI.Unixfs().Ls(context.Background(), dir)
+	entries, err := fs.coreAPI.Unixfs().Ls(ctx, dir)
 	if err != nil {
-		return nil, err
+		return nil, wrapError("list", path, err)
 	}
 
 	dl := make([]*oss.Object, 0)
@@ -291,6 +472,8 @@ func (fs *Ipfs) List(path string) ([]*oss.Object, error) {
 loop:
 	for {
 		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		case entry, ok := <-entries:
 			if !ok {
 				break loop
@@ -309,6 +492,8 @@ loop:
 				Path:             p,
 				Name:             n,
 				LastModified:     &now,
+				Size:             int64(entry.Size),
+				ETag:             entry.Cid.String(),
 				StorageInterface: fs,
 			})
 		}
@@ -322,6 +507,11 @@ func (fs *Ipfs) GetURL(path string) (string, error) {
 	return path, nil
 }
 
+// GetURLCtx no-op
+func (fs *Ipfs) GetURLCtx(ctx context.Context, path string) (string, error) {
+	return path, ctx.Err()
+}
+
 // GetEndpoint no-op
 func (fs *Ipfs) GetEndpoint() string {
 	return "/ipfs"
@@ -452,3 +642,12 @@ func (fs *Ipfs) connectToPeers(ctx context.Context, defaultCfg *config.Config) e
 	wg.Wait()
 	return nil
 }
+
+// wrapError wrap ipfs error into oss.Error, missing nodes and pins are reported as oss.ErrNotExist
+func wrapError(op, path string, err error) error {
+	var statusCode int
+	if errors.Is(err, ipld.ErrNotFound) || errors.Is(err, pin.ErrNotPinned) {
+		statusCode = http.StatusNotFound
+	}
+	return oss.NewError(op, path, statusCode, err)
+}
@@ -0,0 +1,204 @@
+package oss
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultMaxKeys default max number of entries of a listed page
+const DefaultMaxKeys = 1000
+
+// ListOptions options to list a page of objects
+type ListOptions struct {
+	// Prefix only list objects whose key begins with prefix
+	Prefix string
+	// Delimiter groups keys that contain delimiter after prefix into CommonPrefixes, use "/" to list folder by folder
+	Delimiter string
+	// StartAfter only list keys after it
+	StartAfter string
+	// ContinuationToken continue listing from previous page's NextContinuationToken
+	ContinuationToken string
+	// MaxKeys max number of objects and common prefixes in the page, DefaultMaxKeys if not set
+	MaxKeys int
+}
+
+// ListResult a page of listed objects
+type ListResult struct {
+	Objects []*Object
+	// CommonPrefixes "directories" grouped by delimiter, including the delimiter
+	CommonPrefixes []string
+	// NextContinuationToken token to list next page, empty if this is the last page
+	NextContinuationToken string
+}
+
+// PageLister is implemented by storages that could list objects page by page
+type PageLister interface {
+	ListPage(options ListOptions) (*ListResult, error)
+}
+
+// ListPage list a page of objects with storage's ListPage, storages don't implement PageLister
+// fall back to List all objects under the prefix's directory and paginating them in memory
+func ListPage(storage StorageInterface, options ListOptions) (*ListResult, error) {
+	if lister, ok := storage.(PageLister); ok {
+		return lister.ListPage(options)
+	}
+
+	dir := ""
+	if i := strings.LastIndex(options.Prefix, "/"); i >= 0 {
+		dir = options.Prefix[:i]
+	}
+
+	objects, err := storage.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	return NewListResult(objects, nil, options), nil
+}
+
+// NewListResult paginate objects in memory for storages without native pagination. Keys are objects' paths without
+// leading "/", objects are grouped by options' delimiter, prefixes are additional common prefixes (e.g. directories) to return.
+// The continuation token is the last key of the page
+func NewListResult(objects []*Object, prefixes []string, options ListOptions) *ListResult {
+	type entry struct {
+		key    string
+		object *Object
+	}
+
+	var (
+		entries      []entry
+		seenPrefixes = map[string]bool{}
+		prefix       = strings.TrimPrefix(options.Prefix, "/")
+		addPrefix    = func(p string) {
+			if !seenPrefixes[p] {
+				seenPrefixes[p] = true
+				entries = append(entries, entry{key: p})
+			}
+		}
+	)
+
+	for _, object := range objects {
+		key := strings.TrimPrefix(object.Path, "/")
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		if options.Delimiter != "" {
+			if i := strings.Index(key[len(prefix):], options.Delimiter); i >= 0 {
+				addPrefix(key[:len(prefix)+i+len(options.Delimiter)])
+				continue
+			}
+		}
+		entries = append(entries, entry{key: key, object: object})
+	}
+
+	for _, p := range prefixes {
+		if p = strings.TrimPrefix(p, "/"); strings.HasPrefix(p, prefix) {
+			addPrefix(p)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	marker := strings.TrimPrefix(options.StartAfter, "/")
+	if options.ContinuationToken > marker {
+		marker = options.ContinuationToken
+	}
+
+	maxKeys := options.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxKeys
+	}
+
+	result := &ListResult{}
+	for _, e := range entries {
+		if e.key <= marker {
+			continue
+		}
+
+		if len(result.Objects)+len(result.CommonPrefixes) == maxKeys {
+			result.NextContinuationToken = marker
+			break
+		}
+
+		if e.object != nil {
+			result.Objects = append(result.Objects, e.object)
+		} else {
+			result.CommonPrefixes = append(result.CommonPrefixes, e.key)
+		}
+		marker = e.key
+	}
+
+	return result
+}
+
+// ListIterator iterates over listed objects and common prefixes, following pages transparently
+//
+//	iterator := oss.NewListIterator(storage, oss.ListOptions{Prefix: "images/", Delimiter: "/"})
+//	for iterator.Next() {
+//	  if object := iterator.Object(); object != nil {
+//	    ...
+//	  } else {
+//	    prefix := iterator.Prefix()
+//	  }
+//	}
+//	err := iterator.Err()
+type ListIterator struct {
+	storage StorageInterface
+	options ListOptions
+	page    *ListResult
+	index   int
+	object  *Object
+	prefix  string
+	err     error
+}
+
+// NewListIterator create an iterator listing storage with options
+func NewListIterator(storage StorageInterface, options ListOptions) *ListIterator {
+	return &ListIterator{storage: storage, options: options}
+}
+
+// Next advance to next object or common prefix, returns false when done or failed
+func (iterator *ListIterator) Next() bool {
+	iterator.object, iterator.prefix = nil, ""
+
+	for iterator.err == nil {
+		if page := iterator.page; page != nil {
+			if iterator.index < len(page.Objects) {
+				iterator.object = page.Objects[iterator.index]
+				iterator.index++
+				return true
+			}
+
+			if i := iterator.index - len(page.Objects); i < len(page.CommonPrefixes) {
+				iterator.prefix = page.CommonPrefixes[i]
+				iterator.index++
+				return true
+			}
+
+			if page.NextContinuationToken == "" {
+				return false
+			}
+			iterator.options.ContinuationToken = page.NextContinuationToken
+		}
+
+		iterator.page, iterator.err = ListPage(iterator.storage, iterator.options)
+		iterator.index = 0
+	}
+
+	return false
+}
+
+// Object current object, nil if current entry is a common prefix
+func (iterator *ListIterator) Object() *Object {
+	return iterator.object
+}
+
+// Prefix current common prefix, empty if current entry is an object
+func (iterator *ListIterator) Prefix() string {
+	return iterator.prefix
+}
+
+// Err error happened while listing
+func (iterator *ListIterator) Err() error {
+	return iterator.err
+}
@@ -0,0 +1,63 @@
+package oss_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/qor/oss"
+)
+
+func TestNewListResult(t *testing.T) {
+	var objects []*oss.Object
+	for _, path := range []string{"/a/1.txt", "/a/2.txt", "/a/b/3.txt", "/a/c/4.txt", "/d/5.txt"} {
+		objects = append(objects, &oss.Object{Path: path})
+	}
+
+	result := oss.NewListResult(objects, nil, oss.ListOptions{Prefix: "a/", Delimiter: "/", MaxKeys: 3})
+	if len(result.Objects) != 2 || result.Objects[0].Path != "/a/1.txt" || result.Objects[1].Path != "/a/2.txt" {
+		t.Errorf("Should list a/1.txt and a/2.txt, but got %v objects", len(result.Objects))
+	}
+	if !reflect.DeepEqual(result.CommonPrefixes, []string{"a/b/"}) {
+		t.Errorf("Should list common prefix a/b/, but got %v", result.CommonPrefixes)
+	}
+	if result.NextContinuationToken != "a/b/" {
+		t.Errorf("Next continuation token should be a/b/, but got %v", result.NextContinuationToken)
+	}
+
+	result = oss.NewListResult(objects, nil, oss.ListOptions{Prefix: "a/", Delimiter: "/", MaxKeys: 3, ContinuationToken: "a/b/"})
+	if len(result.Objects) != 0 || !reflect.DeepEqual(result.CommonPrefixes, []string{"a/c/"}) || result.NextContinuationToken != "" {
+		t.Errorf("Second page should only contain a/c/, but got %v, %v, %v", len(result.Objects), result.CommonPrefixes, result.NextContinuationToken)
+	}
+
+	result = oss.NewListResult(objects, nil, oss.ListOptions{StartAfter: "/a/c/4.txt"})
+	if len(result.Objects) != 1 || result.Objects[0].Path != "/d/5.txt" {
+		t.Errorf("Should only list objects after a/c/4.txt, but got %v objects", len(result.Objects))
+	}
+}
+
+func TestListIterator(t *testing.T) {
+	storage := plainStorage{content: map[string][]byte{}}
+	for _, path := range []string{"/a/1.txt", "/a/2.txt", "/a/b/3.txt", "/a/c/4.txt", "/a/5.txt"} {
+		storage.content[path] = []byte("sample")
+	}
+
+	var paths, prefixes []string
+	iterator := oss.NewListIterator(storage, oss.ListOptions{Prefix: "/a/", Delimiter: "/", MaxKeys: 2})
+	for iterator.Next() {
+		if object := iterator.Object(); object != nil {
+			paths = append(paths, object.Path)
+		} else {
+			prefixes = append(prefixes, iterator.Prefix())
+		}
+	}
+
+	if err := iterator.Err(); err != nil {
+		t.Errorf("No error should happen when iterate objects, but got %v", err)
+	}
+	if !reflect.DeepEqual(paths, []string{"/a/1.txt", "/a/2.txt", "/a/5.txt"}) {
+		t.Errorf("Should iterate all objects under a/, but got %v", paths)
+	}
+	if !reflect.DeepEqual(prefixes, []string{"a/b/", "a/c/"}) {
+		t.Errorf("Should iterate all common prefixes under a/, but got %v", prefixes)
+	}
+}
@@ -0,0 +1,422 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qor/oss"
+)
+
+var (
+	_ oss.ContextStorage = (*Memory)(nil)
+	_ oss.Stater         = (*Memory)(nil)
+	_ oss.PageLister     = (*Memory)(nil)
+	_ oss.OptionsPutter  = (*Memory)(nil)
+	_ oss.Copier         = (*Memory)(nil)
+	_ oss.Mover          = (*Memory)(nil)
+	_ oss.RangeGetter    = (*Memory)(nil)
+	_ oss.BatchDeleter   = (*Memory)(nil)
+)
+
+func init() {
+	oss.Register("mem", func(u *url.URL) (oss.StorageInterface, error) {
+		return New(), nil
+	})
+}
+
+// Memory in-memory storage for tests and ephemeral caches, it is safe for concurrent use, the zero value is an empty storage ready to use
+type Memory struct {
+	// Latency simulated latency of each operation, operations with context return early once the context is done
+	Latency time.Duration
+	// Failure injects failures, it is called with operation name (get, put, stat, delete, list, copy, move) and path
+	// before each operation, the operation fails with returned error if it is not nil
+	Failure func(op, path string) error
+
+	mutex   sync.RWMutex
+	objects map[string]*object
+}
+
+type object struct {
+	content []byte
+	info    oss.Object
+}
+
+// New initialize Memory storage
+func New() *Memory {
+	return &Memory{objects: map[string]*object{}}
+}
+
+// store store obj at objKey, the caller should hold the write lock
+func (memory *Memory) store(objKey string, obj *object) {
+	if memory.objects == nil {
+		memory.objects = map[string]*object{}
+	}
+	memory.objects[objKey] = obj
+}
+
+// key normalize path to object key, keys always begin with "/"
+func key(path string) string {
+	return "/" + strings.TrimPrefix(path, "/")
+}
+
+// simulate sleep for Latency and run Failure before operation op
+func (memory *Memory) simulate(ctx context.Context, op, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if memory.Latency > 0 {
+		timer := time.NewTimer(memory.Latency)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if memory.Failure != nil {
+		if err := memory.Failure(op, path); err != nil {
+			return oss.NewError(op, path, 0, err)
+		}
+	}
+	return nil
+}
+
+// load get object, returns an error matching oss.ErrNotExist if it doesn't exist
+func (memory *Memory) load(op, path string) (*object, error) {
+	memory.mutex.RLock()
+	obj, ok := memory.objects[key(path)]
+	memory.mutex.RUnlock()
+
+	if !ok {
+		return nil, oss.NewError(op, path, http.StatusNotFound, fmt.Errorf("%s doesn't exist", path))
+	}
+	return obj, nil
+}
+
+// newObject returns a copy of object's info, so callers can't change stored object
+func (memory *Memory) newObject(obj *object) *oss.Object {
+	info := obj.info
+	if info.Metadata != nil {
+		info.Metadata = map[string]string{}
+		for name, value := range obj.info.Metadata {
+			info.Metadata[name] = value
+		}
+	}
+	return &info
+}
+
+// Get receive file with given path, the content is written into a temporary file
+func (memory *Memory) Get(path string) (*os.File, error) {
+	return memory.GetCtx(context.Background(), path)
+}
+
+// GetCtx receive file with given path, the content is written into a temporary file
+func (memory *Memory) GetCtx(ctx context.Context, path string) (*os.File, error) {
+	if err := memory.simulate(ctx, "get", path); err != nil {
+		return nil, err
+	}
+
+	obj, err := memory.load("get", path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := ioutil.TempFile("", "memory*"+filepath.Ext(path))
+	if err != nil {
+		return nil, err
+	}
+	// the file is kept readable until closed
+	os.Remove(file.Name())
+
+	if _, err = file.Write(obj.content); err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// GetStream get file as stream
+func (memory *Memory) GetStream(path string) (io.ReadCloser, error) {
+	return memory.GetStreamCtx(context.Background(), path)
+}
+
+// GetStreamCtx get file as stream, reading fails once ctx is done
+func (memory *Memory) GetStreamCtx(ctx context.Context, path string) (io.ReadCloser, error) {
+	if err := memory.simulate(ctx, "get", path); err != nil {
+		return nil, err
+	}
+
+	obj, err := memory.load("get", path)
+	if err != nil {
+		return nil, err
+	}
+	return oss.ContextReadCloser(ctx, ioutil.NopCloser(bytes.NewReader(obj.content))), nil
+}
+
+// GetRange get length bytes of object starting at offset
+func (memory *Memory) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, &oss.Error{Op: "get", Path: path, Err: fmt.Errorf("oss: negative offset %d", offset)}
+	}
+
+	if err := memory.simulate(context.Background(), "get", path); err != nil {
+		return nil, err
+	}
+
+	obj, err := memory.load("get", path)
+	if err != nil {
+		return nil, err
+	}
+
+	content := obj.content
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	content = content[offset:]
+	if length >= 0 && length < int64(len(content)) {
+		content = content[:length]
+	}
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+// Put store a reader into given path
+func (memory *Memory) Put(path string, reader io.Reader) (*oss.Object, error) {
+	return memory.PutCtx(context.Background(), path, reader)
+}
+
+// PutCtx store a reader into given path, reading stops once ctx is done
+func (memory *Memory) PutCtx(ctx context.Context, path string, reader io.Reader) (*oss.Object, error) {
+	return memory.putCtx(ctx, path, reader, &oss.PutOptions{})
+}
+
+// PutWithOptions store a reader into given path with options, all options are stored, ACL is ignored
+func (memory *Memory) PutWithOptions(path string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	if options == nil {
+		options = &oss.PutOptions{}
+	}
+	return memory.putCtx(context.Background(), path, reader, options)
+}
+
+func (memory *Memory) putCtx(ctx context.Context, path string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	if err := memory.simulate(ctx, "put", path); err != nil {
+		return nil, err
+	}
+
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		seeker.Seek(0, io.SeekStart)
+	}
+
+	checksum := oss.NewChecksumReader(reader)
+	content, err := ioutil.ReadAll(oss.ContextReader(ctx, checksum))
+	if err != nil {
+		return nil, oss.NewError("put", path, 0, err)
+	}
+
+	contentType := options.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(path))
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+
+	var (
+		now     = time.Now()
+		sum     = md5.Sum(content)
+		objKey  = key(path)
+		newInfo = oss.Object{
+			Path:             objKey,
+			Name:             filepath.Base(objKey),
+			LastModified:     &now,
+			Size:             int64(len(content)),
+			ETag:             hex.EncodeToString(sum[:]),
+			ContentType:      contentType,
+			StorageClass:     "STANDARD",
+			StorageInterface: memory,
+		}
+	)
+
+	if len(options.Metadata) > 0 {
+		newInfo.Metadata = map[string]string{}
+		for name, value := range options.Metadata {
+			newInfo.Metadata[name] = value
+		}
+	}
+
+	obj := &object{content: content, info: newInfo}
+	memory.mutex.Lock()
+	memory.store(objKey, obj)
+	memory.mutex.Unlock()
+
+	object := memory.newObject(obj)
+	object.Checksum = checksum.Checksum()
+	return object, nil
+}
+
+// Stat get object's metadata
+func (memory *Memory) Stat(path string) (*oss.Object, error) {
+	if err := memory.simulate(context.Background(), "stat", path); err != nil {
+		return nil, err
+	}
+
+	obj, err := memory.load("stat", path)
+	if err != nil {
+		return nil, err
+	}
+	return memory.newObject(obj), nil
+}
+
+// Delete delete object
+func (memory *Memory) Delete(path string) error {
+	return memory.DeleteCtx(context.Background(), path)
+}
+
+// DeleteCtx delete object
+func (memory *Memory) DeleteCtx(ctx context.Context, path string) error {
+	if err := memory.simulate(ctx, "delete", path); err != nil {
+		return err
+	}
+
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+
+	if _, ok := memory.objects[key(path)]; !ok {
+		return oss.NewError("delete", path, http.StatusNotFound, fmt.Errorf("%s doesn't exist", path))
+	}
+	delete(memory.objects, key(path))
+	return nil
+}
+
+// DeleteMany delete objects at paths
+func (memory *Memory) DeleteMany(paths []string) map[string]error {
+	errs := map[string]error{}
+	for _, path := range paths {
+		if err := memory.Delete(path); err != nil {
+			errs[path] = err
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Copy copy object from src to dst
+func (memory *Memory) Copy(src, dst string) error {
+	if err := memory.simulate(context.Background(), "copy", src); err != nil {
+		return err
+	}
+
+	obj, err := memory.load("copy", src)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	copied := &object{content: obj.content, info: *memory.newObject(obj)}
+	copied.info.Path = key(dst)
+	copied.info.Name = filepath.Base(copied.info.Path)
+	copied.info.LastModified = &now
+
+	memory.mutex.Lock()
+	memory.store(key(dst), copied)
+	memory.mutex.Unlock()
+	return nil
+}
+
+// Move move object from src to dst
+func (memory *Memory) Move(src, dst string) error {
+	if err := memory.simulate(context.Background(), "move", src); err != nil {
+		return err
+	}
+
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+
+	obj, ok := memory.objects[key(src)]
+	if !ok {
+		return oss.NewError("move", src, http.StatusNotFound, fmt.Errorf("%s doesn't exist", src))
+	}
+
+	moved := &object{content: obj.content, info: obj.info}
+	moved.info.Path = key(dst)
+	moved.info.Name = filepath.Base(moved.info.Path)
+
+	delete(memory.objects, key(src))
+	memory.store(key(dst), moved)
+	return nil
+}
+
+// List list all objects under current path
+func (memory *Memory) List(path string) ([]*oss.Object, error) {
+	return memory.ListCtx(context.Background(), path)
+}
+
+// ListCtx list all objects under current path, sorted by path
+func (memory *Memory) ListCtx(ctx context.Context, path string) ([]*oss.Object, error) {
+	if err := memory.simulate(ctx, "list", path); err != nil {
+		return nil, err
+	}
+
+	prefix := strings.TrimSuffix(key(path), "/") + "/"
+	return memory.objectsWithPrefix(prefix), nil
+}
+
+// ListPage list a page of objects
+func (memory *Memory) ListPage(options oss.ListOptions) (*oss.ListResult, error) {
+	if err := memory.simulate(context.Background(), "list", options.Prefix); err != nil {
+		return nil, err
+	}
+	return oss.NewListResult(memory.objectsWithPrefix(key(options.Prefix)), nil, options), nil
+}
+
+func (memory *Memory) objectsWithPrefix(prefix string) []*oss.Object {
+	memory.mutex.RLock()
+	defer memory.mutex.RUnlock()
+
+	var objects []*oss.Object
+	for objKey, obj := range memory.objects {
+		if strings.HasPrefix(objKey, prefix) {
+			objects = append(objects, memory.newObject(obj))
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Path < objects[j].Path })
+	return objects
+}
+
+// GetEndpoint get endpoint, Memory's endpoint is /
+func (memory *Memory) GetEndpoint() string {
+	return "/"
+}
+
+// GetURL get URL, it is the object's path
+func (memory *Memory) GetURL(path string) (string, error) {
+	return memory.GetURLCtx(context.Background(), path)
+}
+
+// GetURLCtx get URL, it is the object's path
+func (memory *Memory) GetURLCtx(ctx context.Context, path string) (string, error) {
+	return key(path), ctx.Err()
+}
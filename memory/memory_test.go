@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qor/oss"
+	"github.com/qor/oss/tests"
+)
+
+func TestAll(t *testing.T) {
+	tests.TestAll(New(), t)
+}
+
+func TestWrapped(t *testing.T) {
+	tests.TestAll(oss.Wrap(New(), oss.Timing(func(call *oss.Call) {})), t)
+}
+
+func TestPrefixed(t *testing.T) {
+	tests.TestAll(oss.WithPrefix(New(), "tenants/1"), t)
+}
+
+func TestGet(t *testing.T) {
+	memory := New()
+	memory.Put("/sample.txt", strings.NewReader("sample"))
+
+	file, err := memory.Get("/sample.txt")
+	if err != nil {
+		t.Fatalf("No error should happen when get sample file, but got %v", err)
+	}
+	defer file.Close()
+
+	if content, err := ioutil.ReadAll(file); err != nil || string(content) != "sample" {
+		t.Errorf("Got file should contain sample, but got %v, %v", string(content), err)
+	}
+
+	if _, err := memory.Get("/missing.txt"); !errors.Is(err, oss.ErrNotExist) {
+		t.Errorf("Getting missing file should fail with oss.ErrNotExist, but got %v", err)
+	}
+}
+
+func TestZeroValue(t *testing.T) {
+	var memory Memory
+	if _, err := memory.Put("/sample.txt", strings.NewReader("sample")); err != nil {
+		t.Fatalf("No error should happen when put into zero value Memory, but got %v", err)
+	}
+
+	if err := memory.Copy("/sample.txt", "/copied.txt"); err != nil {
+		t.Errorf("No error should happen when copy in zero value Memory, but got %v", err)
+	}
+
+	if objects, err := memory.List("/"); err != nil || len(objects) != 2 {
+		t.Errorf("Zero value Memory should list stored objects, but got %v, %v", objects, err)
+	}
+}
+
+func TestGetRangeNegativeOffset(t *testing.T) {
+	memory := New()
+	memory.Put("/sample.txt", strings.NewReader("sample"))
+
+	var ossErr *oss.Error
+	if _, err := memory.GetRange("/sample.txt", -1, 2); !errors.As(err, &ossErr) {
+		t.Errorf("Getting range with negative offset should fail with *oss.Error, but got %v", err)
+	}
+}
+
+func TestFailure(t *testing.T) {
+	memory := New()
+	memory.Failure = func(op, path string) error {
+		if op == "put" && strings.HasSuffix(path, ".exe") {
+			return oss.ErrPermission
+		}
+		return nil
+	}
+
+	if _, err := memory.Put("/virus.exe", strings.NewReader("sample")); !errors.Is(err, oss.ErrPermission) {
+		t.Errorf("Injected failure should be returned, but got %v", err)
+	}
+
+	if _, err := memory.Put("/sample.txt", strings.NewReader("sample")); err != nil {
+		t.Errorf("No error should happen when put sample file, but got %v", err)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	memory := New()
+	memory.Latency = 50 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := memory.PutCtx(ctx, "/sample.txt", strings.NewReader("sample")); err != context.DeadlineExceeded {
+		t.Errorf("Put should fail when context is done before latency, but got %v", err)
+	}
+
+	start := time.Now()
+	if _, err := memory.Put("/sample.txt", strings.NewReader("sample")); err != nil {
+		t.Errorf("No error should happen when put sample file, but got %v", err)
+	} else if time.Since(start) < memory.Latency {
+		t.Errorf("Put should take at least %v", memory.Latency)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	var (
+		memory = New()
+		wg     sync.WaitGroup
+	)
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path := fmt.Sprintf("/%v/sample.txt", i)
+			memory.Put(path, strings.NewReader("sample"))
+			memory.Stat(path)
+			memory.List("/")
+			memory.Copy(path, path+".bak")
+			memory.Delete(path)
+		}(i)
+	}
+	wg.Wait()
+
+	if objects, _ := memory.List("/"); len(objects) != 20 {
+		t.Errorf("Should left 20 copied objects, but got %v", len(objects))
+	}
+}
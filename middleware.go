@@ -0,0 +1,336 @@
+package oss
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Call an intercepted storage operation
+type Call struct {
+	Context context.Context
+	// Op name of called method, e.g. Get, GetStream, Put, Delete, List, GetURL, Stat, Copy
+	Op string
+	// Path path of the object, source path for Copy and Move, prefix for ListPage
+	Path string
+	// Body reader to upload of Put and PutWithOptions, nil for other operations
+	Body  io.Reader
+	Start time.Time
+	// Duration, Bytes and Err are set once the operation is done, streams are done when closed
+	Duration time.Duration
+	// Bytes number of bytes transferred
+	Bytes int64
+	Err   error
+
+	mutex  sync.Mutex
+	stream bool
+	done   bool
+	hooks  []func(call *Call)
+}
+
+// OnDone register fn to be called once the operation is done, hooks run in reverse order of registration
+func (call *Call) OnDone(fn func(call *Call)) {
+	call.mutex.Lock()
+	defer call.mutex.Unlock()
+	call.hooks = append(call.hooks, fn)
+}
+
+func (call *Call) finish(err error) {
+	call.mutex.Lock()
+	if call.done {
+		call.mutex.Unlock()
+		return
+	}
+	call.done = true
+	call.Err = err
+	call.Duration = time.Since(call.Start)
+	hooks := call.hooks
+	call.mutex.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i](call)
+	}
+}
+
+// Middleware intercepts storage operations, it calls next to run the operation (or next middleware),
+// and could register OnDone hooks to inspect duration, bytes transferred and error
+type Middleware func(call *Call, next func() error) error
+
+// Wrap wrap storage with middlewares, the first middleware is the outermost one. All operations, including the
+// optional ones like Stat and Copy, go through middlewares, storages don't support them natively use the
+// package level fallbacks
+func Wrap(storage StorageInterface, middlewares ...Middleware) ContextStorage {
+	return &wrappedStorage{storage: storage, middlewares: middlewares}
+}
+
+var (
+	_ ContextStorage = (*wrappedStorage)(nil)
+	_ Stater         = (*wrappedStorage)(nil)
+	_ PageLister     = (*wrappedStorage)(nil)
+	_ OptionsPutter  = (*wrappedStorage)(nil)
+	_ Copier         = (*wrappedStorage)(nil)
+	_ Mover          = (*wrappedStorage)(nil)
+	_ RangeGetter    = (*wrappedStorage)(nil)
+	_ BatchDeleter   = (*wrappedStorage)(nil)
+)
+
+type wrappedStorage struct {
+	storage     StorageInterface
+	middlewares []Middleware
+}
+
+// invoke run operation through middlewares
+func (wrapped *wrappedStorage) invoke(call *Call, operation func() error) error {
+	handler := operation
+	for i := len(wrapped.middlewares) - 1; i >= 0; i-- {
+		middleware, next := wrapped.middlewares[i], handler
+		handler = func() error { return middleware(call, next) }
+	}
+
+	call.Start = time.Now()
+	err := handler()
+	if err != nil || !call.stream {
+		call.finish(err)
+	}
+	return err
+}
+
+func newCall(ctx context.Context, op, path string) *Call {
+	return &Call{Context: ctx, Op: op, Path: path}
+}
+
+// trackStream finish call when stream is closed, counting bytes read
+func trackStream(call *Call, stream io.ReadCloser) io.ReadCloser {
+	call.stream = true
+	return &trackedStream{call: call, stream: stream}
+}
+
+type trackedStream struct {
+	call   *Call
+	stream io.ReadCloser
+	err    error
+}
+
+func (stream *trackedStream) Read(p []byte) (int, error) {
+	n, err := stream.stream.Read(p)
+	stream.call.mutex.Lock()
+	stream.call.Bytes += int64(n)
+	stream.call.mutex.Unlock()
+	if err != nil && err != io.EOF {
+		stream.err = err
+	}
+	return n, err
+}
+
+func (stream *trackedStream) Close() error {
+	err := stream.stream.Close()
+	if stream.err != nil {
+		stream.call.finish(stream.err)
+	} else {
+		stream.call.finish(err)
+	}
+	return err
+}
+
+func (wrapped *wrappedStorage) Get(path string) (*os.File, error) {
+	return wrapped.GetCtx(context.Background(), path)
+}
+
+func (wrapped *wrappedStorage) GetCtx(ctx context.Context, path string) (file *os.File, err error) {
+	call := newCall(ctx, "Get", path)
+	err = wrapped.invoke(call, func() (err error) {
+		if file, err = WithContext(wrapped.storage).GetCtx(ctx, path); err == nil {
+			if info, err := file.Stat(); err == nil {
+				call.Bytes = info.Size()
+			}
+		}
+		return err
+	})
+	return file, err
+}
+
+func (wrapped *wrappedStorage) GetStream(path string) (io.ReadCloser, error) {
+	return wrapped.GetStreamCtx(context.Background(), path)
+}
+
+func (wrapped *wrappedStorage) GetStreamCtx(ctx context.Context, path string) (stream io.ReadCloser, err error) {
+	call := newCall(ctx, "GetStream", path)
+	err = wrapped.invoke(call, func() (err error) {
+		if stream, err = WithContext(wrapped.storage).GetStreamCtx(ctx, path); err == nil {
+			stream = trackStream(call, stream)
+		}
+		return err
+	})
+	return stream, err
+}
+
+func (wrapped *wrappedStorage) GetRange(path string, offset, length int64) (stream io.ReadCloser, err error) {
+	call := newCall(context.Background(), "GetRange", path)
+	err = wrapped.invoke(call, func() (err error) {
+		if stream, err = GetRange(wrapped.storage, path, offset, length); err == nil {
+			stream = trackStream(call, stream)
+		}
+		return err
+	})
+	return stream, err
+}
+
+func (wrapped *wrappedStorage) Put(path string, reader io.Reader) (*Object, error) {
+	return wrapped.PutCtx(context.Background(), path, reader)
+}
+
+func (wrapped *wrappedStorage) PutCtx(ctx context.Context, path string, reader io.Reader) (object *Object, err error) {
+	call := newCall(ctx, "Put", path)
+	call.Body = reader
+	err = wrapped.invoke(call, func() (err error) {
+		if object, err = WithContext(wrapped.storage).PutCtx(ctx, path, reader); err == nil && object != nil {
+			call.Bytes = object.Size
+		}
+		return err
+	})
+	if object != nil {
+		object.StorageInterface = wrapped
+	}
+	return object, err
+}
+
+func (wrapped *wrappedStorage) PutWithOptions(path string, reader io.Reader, options *PutOptions) (object *Object, err error) {
+	call := newCall(context.Background(), "PutWithOptions", path)
+	call.Body = reader
+	err = wrapped.invoke(call, func() (err error) {
+		if object, err = PutWithOptions(wrapped.storage, path, reader, options); err == nil && object != nil {
+			call.Bytes = object.Size
+		}
+		return err
+	})
+	if object != nil {
+		object.StorageInterface = wrapped
+	}
+	return object, err
+}
+
+func (wrapped *wrappedStorage) Stat(path string) (object *Object, err error) {
+	err = wrapped.invoke(newCall(context.Background(), "Stat", path), func() (err error) {
+		object, err = Stat(wrapped.storage, path)
+		return err
+	})
+	if object != nil {
+		object.StorageInterface = wrapped
+	}
+	return object, err
+}
+
+func (wrapped *wrappedStorage) Delete(path string) error {
+	return wrapped.DeleteCtx(context.Background(), path)
+}
+
+func (wrapped *wrappedStorage) DeleteCtx(ctx context.Context, path string) error {
+	return wrapped.invoke(newCall(ctx, "Delete", path), func() error {
+		return WithContext(wrapped.storage).DeleteCtx(ctx, path)
+	})
+}
+
+func (wrapped *wrappedStorage) DeleteMany(paths []string) (errs map[string]error) {
+	err := wrapped.invoke(newCall(context.Background(), "DeleteMany", ""), func() error {
+		if errs = DeleteMany(wrapped.storage, paths); len(errs) > 0 {
+			return BatchError(errs)
+		}
+		return nil
+	})
+
+	// a middleware failed the operation without running it
+	if err != nil && len(errs) == 0 {
+		errs = map[string]error{}
+		for _, path := range paths {
+			errs[path] = err
+		}
+	}
+	return errs
+}
+
+func (wrapped *wrappedStorage) Copy(src, dst string) error {
+	return wrapped.invoke(newCall(context.Background(), "Copy", src), func() error {
+		return Copy(wrapped.storage, src, dst)
+	})
+}
+
+func (wrapped *wrappedStorage) Move(src, dst string) error {
+	return wrapped.invoke(newCall(context.Background(), "Move", src), func() error {
+		return Move(wrapped.storage, src, dst)
+	})
+}
+
+func (wrapped *wrappedStorage) List(path string) ([]*Object, error) {
+	return wrapped.ListCtx(context.Background(), path)
+}
+
+func (wrapped *wrappedStorage) ListCtx(ctx context.Context, path string) (objects []*Object, err error) {
+	err = wrapped.invoke(newCall(ctx, "List", path), func() (err error) {
+		objects, err = WithContext(wrapped.storage).ListCtx(ctx, path)
+		return err
+	})
+	for _, object := range objects {
+		object.StorageInterface = wrapped
+	}
+	return objects, err
+}
+
+func (wrapped *wrappedStorage) ListPage(options ListOptions) (result *ListResult, err error) {
+	err = wrapped.invoke(newCall(context.Background(), "ListPage", options.Prefix), func() (err error) {
+		result, err = ListPage(wrapped.storage, options)
+		return err
+	})
+	if result != nil {
+		for _, object := range result.Objects {
+			object.StorageInterface = wrapped
+		}
+	}
+	return result, err
+}
+
+func (wrapped *wrappedStorage) GetURL(path string) (string, error) {
+	return wrapped.GetURLCtx(context.Background(), path)
+}
+
+func (wrapped *wrappedStorage) GetURLCtx(ctx context.Context, path string) (url string, err error) {
+	err = wrapped.invoke(newCall(ctx, "GetURL", path), func() (err error) {
+		url, err = WithContext(wrapped.storage).GetURLCtx(ctx, path)
+		return err
+	})
+	return url, err
+}
+
+func (wrapped *wrappedStorage) GetEndpoint() string {
+	return wrapped.storage.GetEndpoint()
+}
+
+// Logger structured logger used by Logging middleware, *slog.Logger satisfies it
+type Logger interface {
+	Info(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// Logging middleware logs each operation with op, path, duration and bytes as key-value pairs,
+// failed operations are logged at error level with the error
+func Logging(logger Logger) Middleware {
+	return func(call *Call, next func() error) error {
+		call.OnDone(func(call *Call) {
+			if call.Err != nil {
+				logger.Error("oss operation failed", "op", call.Op, "path", call.Path, "duration", call.Duration, "bytes", call.Bytes, "error", call.Err)
+			} else {
+				logger.Info("oss operation", "op", call.Op, "path", call.Path, "duration", call.Duration, "bytes", call.Bytes)
+			}
+		})
+		return next()
+	}
+}
+
+// Timing middleware calls observe with each finished operation, to record durations and bytes to metrics
+func Timing(observe func(call *Call)) Middleware {
+	return func(call *Call, next func() error) error {
+		call.OnDone(observe)
+		return next()
+	}
+}
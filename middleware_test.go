@@ -0,0 +1,70 @@
+package oss_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/qor/oss"
+)
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (logger *recordingLogger) Info(msg string, args ...interface{}) {
+	logger.lines = append(logger.lines, fmt.Sprint(append([]interface{}{"INFO ", msg}, args...)...))
+}
+
+func (logger *recordingLogger) Error(msg string, args ...interface{}) {
+	logger.lines = append(logger.lines, fmt.Sprint(append([]interface{}{"ERROR ", msg}, args...)...))
+}
+
+func TestWrap(t *testing.T) {
+	var (
+		order  []string
+		calls  []oss.Call
+		logger = &recordingLogger{}
+		trace  = func(name string) oss.Middleware {
+			return func(call *oss.Call, next func() error) error {
+				order = append(order, name+" "+call.Op)
+				return next()
+			}
+		}
+	)
+
+	storage := oss.Wrap(plainStorage{content: map[string][]byte{}}, trace("outer"), trace("inner"), oss.Logging(logger), oss.Timing(func(call *oss.Call) {
+		calls = append(calls, oss.Call{Op: call.Op, Path: call.Path, Bytes: call.Bytes, Err: call.Err})
+	}))
+
+	if _, err := storage.Put("/sample.txt", strings.NewReader("sample")); err != nil {
+		t.Errorf("No error should happen when put, but got %v", err)
+	}
+
+	stream, err := storage.GetStream("/sample.txt")
+	if err != nil {
+		t.Fatalf("No error should happen when get stream, but got %v", err)
+	}
+	ioutil.ReadAll(stream)
+	if len(calls) != 1 {
+		t.Errorf("Stream should be observed when closed, but got %v calls", len(calls))
+	}
+	stream.Close()
+
+	if _, err := storage.GetStream("/missing.txt"); err == nil {
+		t.Errorf("There should be an error when get missing file")
+	}
+
+	if fmt.Sprint(order) != "[outer Put inner Put outer GetStream inner GetStream outer GetStream inner GetStream]" {
+		t.Errorf("Middlewares should be called from outer to inner, but got %v", order)
+	}
+
+	if len(calls) != 3 || calls[1].Op != "GetStream" || calls[1].Bytes != 6 || calls[2].Err == nil {
+		t.Errorf("Timing should observe finished calls with bytes and errors, but got %+v", calls)
+	}
+
+	if len(logger.lines) != 3 || !strings.HasPrefix(logger.lines[0], "INFO oss operation") || !strings.HasPrefix(logger.lines[2], "ERROR oss operation failed") {
+		t.Errorf("Logging should log operations, but got %v", logger.lines)
+	}
+}
@@ -0,0 +1,528 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/qor/oss"
+)
+
+var (
+	_ oss.ContextStorage = (*Mirror)(nil)
+	_ oss.Stater         = (*Mirror)(nil)
+	_ oss.PageLister     = (*Mirror)(nil)
+	_ oss.OptionsPutter  = (*Mirror)(nil)
+	_ oss.Copier         = (*Mirror)(nil)
+	_ oss.Mover          = (*Mirror)(nil)
+	_ oss.RangeGetter    = (*Mirror)(nil)
+	_ oss.BatchDeleter   = (*Mirror)(nil)
+)
+
+// ErrQueueFull is reported to OnError when a repair couldn't be queued, run Repair to reconcile the object
+var ErrQueueFull = errors.New("mirror: repair queue is full")
+
+// Mode decides when writes fanned out to all storages succeed
+type Mode int
+
+const (
+	// WriteAll writes succeed only if they succeed in all storages
+	WriteAll Mode = iota
+	// WritePrimary writes succeed once they succeed in the primary, objects failed to write to secondaries
+	// are queued to be repaired from the primary in background
+	WritePrimary
+)
+
+// Config mirror config
+type Config struct {
+	Mode Mode
+	// QueueSize max number of queued repairs of WritePrimary mode, default is 1000
+	QueueSize int
+	// OnError called with errors of background repairs
+	OnError func(path string, err error)
+}
+
+// Mirror storage replicating objects of a primary storage to secondaries. Writes are fanned out to all storages
+// in parallel, reads come from the primary and fall back to secondaries in order if the primary fails
+type Mirror struct {
+	Primary     oss.StorageInterface
+	Secondaries []oss.StorageInterface
+	config      Config
+
+	mutex  sync.RWMutex
+	closed bool
+	queue  chan repair
+	done   chan struct{}
+}
+
+type repair struct {
+	path      string
+	secondary oss.StorageInterface
+}
+
+// New initialize Mirror storage, the repair queue of WritePrimary mode is processed in background until Close
+func New(config Config, primary oss.StorageInterface, secondaries ...oss.StorageInterface) *Mirror {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 1000
+	}
+
+	mirror := &Mirror{
+		Primary:     primary,
+		Secondaries: secondaries,
+		config:      config,
+		queue:       make(chan repair, config.QueueSize),
+		done:        make(chan struct{}),
+	}
+
+	go func() {
+		defer close(mirror.done)
+		for r := range mirror.queue {
+			if err := syncObject(mirror.Primary, r.secondary, r.path); err != nil {
+				mirror.report(r.path, err)
+			}
+		}
+	}()
+
+	return mirror
+}
+
+// Close stop accepting repairs and wait queued repairs to finish
+func (mirror *Mirror) Close() error {
+	mirror.mutex.Lock()
+	if !mirror.closed {
+		mirror.closed = true
+		close(mirror.queue)
+	}
+	mirror.mutex.Unlock()
+
+	<-mirror.done
+	return nil
+}
+
+func (mirror *Mirror) report(path string, err error) {
+	if mirror.config.OnError != nil {
+		mirror.config.OnError(path, err)
+	}
+}
+
+// enqueue queue repair of path in secondary
+func (mirror *Mirror) enqueue(path string, secondary oss.StorageInterface) {
+	mirror.mutex.RLock()
+	defer mirror.mutex.RUnlock()
+
+	if mirror.closed {
+		mirror.report(path, errors.New("mirror: closed"))
+		return
+	}
+
+	select {
+	case mirror.queue <- repair{path: path, secondary: secondary}:
+	default:
+		mirror.report(path, ErrQueueFull)
+	}
+}
+
+// syncObject make path in secondary the same as in primary, it is deleted if it doesn't exist in primary
+func syncObject(primary, secondary oss.StorageInterface, path string) error {
+	object, err := oss.Stat(primary, path)
+	if errors.Is(err, oss.ErrNotExist) {
+		if err = secondary.Delete(path); errors.Is(err, oss.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	stream, err := primary.GetStream(path)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	_, err = oss.PutWithOptions(secondary, path, stream, &oss.PutOptions{ContentType: object.ContentType, Metadata: object.Metadata})
+	return err
+}
+
+// write run write operation on all storages in parallel. Returns primary's error, and secondaries' errors in WriteAll mode,
+// secondaries failed to write paths are queued to be repaired in WritePrimary mode, errors matching oss.ErrNotExist
+// of secondaries are ignored if ignoreNotExist
+func (mirror *Mirror) write(paths []string, ignoreNotExist bool, fn func(i int, storage oss.StorageInterface) error) error {
+	var (
+		storages = append([]oss.StorageInterface{mirror.Primary}, mirror.Secondaries...)
+		errs     = make([]error, len(storages))
+		wg       sync.WaitGroup
+	)
+
+	for i, storage := range storages {
+		wg.Add(1)
+		go func(i int, storage oss.StorageInterface) {
+			defer wg.Done()
+			errs[i] = fn(i, storage)
+		}(i, storage)
+	}
+	wg.Wait()
+
+	for i, secondary := range mirror.Secondaries {
+		err := errs[i+1]
+		if ignoreNotExist && errors.Is(err, oss.ErrNotExist) {
+			err = nil
+		}
+
+		switch {
+		case mirror.config.Mode == WritePrimary:
+			// secondaries failed to write, or written while primary failed, are repaired from primary
+			if err != nil || errs[0] != nil {
+				for _, path := range paths {
+					mirror.enqueue(path, secondary)
+				}
+			}
+		case err != nil && errs[0] == nil:
+			errs[0] = fmt.Errorf("mirror: secondary %d: %w", i+1, err)
+		}
+	}
+	return errs[0]
+}
+
+// readers get n independent readers of reader's remaining content, readers that aren't io.ReaderAt are copied to a temporary file
+func readers(reader io.Reader, n int) ([]io.Reader, func(), error) {
+	var (
+		cleanup = func() {}
+		at, ok  = reader.(interface {
+			io.ReaderAt
+			io.Seeker
+		})
+	)
+
+	if !ok {
+		file, err := ioutil.TempFile("", "mirror")
+		if err != nil {
+			return nil, nil, err
+		}
+		cleanup = func() {
+			file.Close()
+			os.Remove(file.Name())
+		}
+
+		if reader != nil {
+			_, err = io.Copy(file, reader)
+		}
+		if err == nil {
+			_, err = file.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		at = file
+	}
+
+	offset, err := at.Seek(0, io.SeekCurrent)
+	if err == nil {
+		var end int64
+		if end, err = at.Seek(0, io.SeekEnd); err == nil {
+			readers := make([]io.Reader, n)
+			for i := range readers {
+				readers[i] = io.NewSectionReader(at, offset, end-offset)
+			}
+			return readers, cleanup, nil
+		}
+	}
+
+	cleanup()
+	return nil, nil, err
+}
+
+// read run read operation on primary, and on secondaries in order if it fails with an error other than oss.ErrNotExist
+func (mirror *Mirror) read(fn func(storage oss.StorageInterface) error) error {
+	err := fn(mirror.Primary)
+	if err == nil || errors.Is(err, oss.ErrNotExist) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	for _, secondary := range mirror.Secondaries {
+		if fn(secondary) == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// own make object's methods go through the mirror
+func (mirror *Mirror) own(object *oss.Object) *oss.Object {
+	if object != nil {
+		object.StorageInterface = mirror
+	}
+	return object
+}
+
+// Get receive file with given path from primary, or secondaries if primary fails
+func (mirror *Mirror) Get(path string) (*os.File, error) {
+	return mirror.GetCtx(context.Background(), path)
+}
+
+// GetCtx receive file with given path from primary, or secondaries if primary fails
+func (mirror *Mirror) GetCtx(ctx context.Context, path string) (file *os.File, err error) {
+	err = mirror.read(func(storage oss.StorageInterface) (err error) {
+		file, err = oss.WithContext(storage).GetCtx(ctx, path)
+		return err
+	})
+	return file, err
+}
+
+// GetStream get file as stream from primary, or secondaries if primary fails
+func (mirror *Mirror) GetStream(path string) (io.ReadCloser, error) {
+	return mirror.GetStreamCtx(context.Background(), path)
+}
+
+// GetStreamCtx get file as stream from primary, or secondaries if primary fails
+func (mirror *Mirror) GetStreamCtx(ctx context.Context, path string) (stream io.ReadCloser, err error) {
+	err = mirror.read(func(storage oss.StorageInterface) (err error) {
+		stream, err = oss.WithContext(storage).GetStreamCtx(ctx, path)
+		return err
+	})
+	return stream, err
+}
+
+// GetRange get length bytes of object starting at offset from primary, or secondaries if primary fails
+func (mirror *Mirror) GetRange(path string, offset, length int64) (stream io.ReadCloser, err error) {
+	err = mirror.read(func(storage oss.StorageInterface) (err error) {
+		stream, err = oss.GetRange(storage, path, offset, length)
+		return err
+	})
+	return stream, err
+}
+
+// Put store a reader into given path in all storages
+func (mirror *Mirror) Put(path string, reader io.Reader) (*oss.Object, error) {
+	return mirror.PutCtx(context.Background(), path, reader)
+}
+
+// PutCtx store a reader into given path in all storages
+func (mirror *Mirror) PutCtx(ctx context.Context, path string, reader io.Reader) (*oss.Object, error) {
+	return mirror.put(path, reader, func(storage oss.StorageInterface, reader io.Reader) (*oss.Object, error) {
+		return oss.WithContext(storage).PutCtx(ctx, path, reader)
+	})
+}
+
+// PutWithOptions store a reader into given path with options in all storages
+func (mirror *Mirror) PutWithOptions(path string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	return mirror.put(path, reader, func(storage oss.StorageInterface, reader io.Reader) (*oss.Object, error) {
+		return oss.PutWithOptions(storage, path, reader, options)
+	})
+}
+
+// put put independent readers of reader's content to all storages, returns primary's object
+func (mirror *Mirror) put(path string, reader io.Reader, put func(storage oss.StorageInterface, reader io.Reader) (*oss.Object, error)) (*oss.Object, error) {
+	bodies, cleanup, err := readers(reader, 1+len(mirror.Secondaries))
+	if err != nil {
+		return nil, oss.NewError("put", path, 0, err)
+	}
+	defer cleanup()
+
+	var object *oss.Object
+	err = mirror.write([]string{path}, false, func(i int, storage oss.StorageInterface) error {
+		obj, err := put(storage, bodies[i])
+		if i == 0 {
+			object = obj
+		}
+		return err
+	})
+	return mirror.own(object), err
+}
+
+// Stat get object's metadata from primary, or secondaries if primary fails
+func (mirror *Mirror) Stat(path string) (object *oss.Object, err error) {
+	err = mirror.read(func(storage oss.StorageInterface) (err error) {
+		object, err = oss.Stat(storage, path)
+		return err
+	})
+	return mirror.own(object), err
+}
+
+// Delete delete object from all storages
+func (mirror *Mirror) Delete(path string) error {
+	return mirror.DeleteCtx(context.Background(), path)
+}
+
+// DeleteCtx delete object from all storages, secondaries without the object are ignored
+func (mirror *Mirror) DeleteCtx(ctx context.Context, path string) error {
+	return mirror.write([]string{path}, true, func(i int, storage oss.StorageInterface) error {
+		return oss.WithContext(storage).DeleteCtx(ctx, path)
+	})
+}
+
+// DeleteMany delete objects at paths from all storages, returns primary's errors, and secondaries' errors in WriteAll mode
+func (mirror *Mirror) DeleteMany(paths []string) map[string]error {
+	var (
+		errs  = map[string]error{}
+		mutex sync.Mutex
+	)
+
+	mirror.write(nil, false, func(i int, storage oss.StorageInterface) error {
+		failed := oss.DeleteMany(storage, paths)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		for path, err := range failed {
+			switch {
+			case i == 0:
+				errs[path] = err
+			case errors.Is(err, oss.ErrNotExist):
+			case mirror.config.Mode == WritePrimary:
+				mirror.enqueue(path, storage)
+			case errs[path] == nil:
+				errs[path] = fmt.Errorf("mirror: secondary: %w", err)
+			}
+		}
+		return nil
+	})
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Copy copy object from src to dst in all storages
+func (mirror *Mirror) Copy(src, dst string) error {
+	return mirror.write([]string{dst}, false, func(i int, storage oss.StorageInterface) error {
+		return oss.Copy(storage, src, dst)
+	})
+}
+
+// Move move object from src to dst in all storages
+func (mirror *Mirror) Move(src, dst string) error {
+	return mirror.write([]string{src, dst}, false, func(i int, storage oss.StorageInterface) error {
+		return oss.Move(storage, src, dst)
+	})
+}
+
+// List list all objects under current path from primary, or secondaries if primary fails
+func (mirror *Mirror) List(path string) ([]*oss.Object, error) {
+	return mirror.ListCtx(context.Background(), path)
+}
+
+// ListCtx list all objects under current path from primary, or secondaries if primary fails
+func (mirror *Mirror) ListCtx(ctx context.Context, path string) (objects []*oss.Object, err error) {
+	err = mirror.read(func(storage oss.StorageInterface) (err error) {
+		objects, err = oss.WithContext(storage).ListCtx(ctx, path)
+		return err
+	})
+	for _, object := range objects {
+		mirror.own(object)
+	}
+	return objects, err
+}
+
+// ListPage list a page of objects from primary, or secondaries if primary fails
+func (mirror *Mirror) ListPage(options oss.ListOptions) (result *oss.ListResult, err error) {
+	err = mirror.read(func(storage oss.StorageInterface) (err error) {
+		result, err = oss.ListPage(storage, options)
+		return err
+	})
+	if result != nil {
+		for _, object := range result.Objects {
+			mirror.own(object)
+		}
+	}
+	return result, err
+}
+
+// GetEndpoint get endpoint of primary
+func (mirror *Mirror) GetEndpoint() string {
+	return mirror.Primary.GetEndpoint()
+}
+
+// GetURL get URL from primary, or secondaries if primary fails
+func (mirror *Mirror) GetURL(path string) (string, error) {
+	return mirror.GetURLCtx(context.Background(), path)
+}
+
+// GetURLCtx get URL from primary, or secondaries if primary fails
+func (mirror *Mirror) GetURLCtx(ctx context.Context, path string) (url string, err error) {
+	err = mirror.read(func(storage oss.StorageInterface) (err error) {
+		url, err = oss.WithContext(storage).GetURLCtx(ctx, path)
+		return err
+	})
+	return url, err
+}
+
+// Repair reconcile objects whose path begins with prefix in secondaries with primary: objects missing in a secondary,
+// of different size, or older than in primary are copied from primary, objects not in primary are deleted from secondaries.
+// Secondaries failed to list are skipped and their errors are keyed by prefix. Returns a oss.BatchError of objects failed to repair
+func (mirror *Mirror) Repair(prefix string) error {
+	primaryObjects, err := listAll(mirror.Primary, prefix)
+	if err != nil {
+		return err
+	}
+
+	errs := oss.BatchError{}
+	for i, secondary := range mirror.Secondaries {
+		secondaryObjects, err := listAll(secondary, prefix)
+		if err != nil {
+			addError(errs, prefix, fmt.Errorf("mirror: failed to list secondary %d: %w", i, err))
+			continue
+		}
+
+		for key, object := range primaryObjects {
+			if !outdated(secondaryObjects[key], object) {
+				continue
+			}
+			if err := syncObject(mirror.Primary, secondary, object.Path); err != nil {
+				addError(errs, object.Path, err)
+			}
+		}
+
+		for key, object := range secondaryObjects {
+			if _, ok := primaryObjects[key]; ok {
+				continue
+			}
+			if err := secondary.Delete(object.Path); err != nil && !errors.Is(err, oss.ErrNotExist) {
+				addError(errs, object.Path, err)
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// addError add err of path to errs, errors of the same path in different secondaries are combined
+func addError(errs oss.BatchError, path string, err error) {
+	if previous, ok := errs[path]; ok {
+		err = fmt.Errorf("%v; %w", previous, err)
+	}
+	errs[path] = err
+}
+
+// outdated check secondary object is missing, of different size, or older than primary object
+func outdated(secondary, primary *oss.Object) bool {
+	if secondary == nil || secondary.Size != primary.Size {
+		return true
+	}
+	return secondary.LastModified != nil && primary.LastModified != nil && secondary.LastModified.Before(*primary.LastModified)
+}
+
+// listAll list all objects whose path begins with prefix keyed by path without leading "/"
+func listAll(storage oss.StorageInterface, prefix string) (map[string]*oss.Object, error) {
+	var (
+		objects  = map[string]*oss.Object{}
+		iterator = oss.NewListIterator(storage, oss.ListOptions{Prefix: prefix})
+	)
+
+	for iterator.Next() {
+		if object := iterator.Object(); object != nil {
+			objects[strings.TrimPrefix(object.Path, "/")] = object
+		}
+	}
+	return objects, iterator.Err()
+}
@@ -0,0 +1,171 @@
+package mirror
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/qor/oss"
+	"github.com/qor/oss/memory"
+	"github.com/qor/oss/tests"
+)
+
+func content(t *testing.T, storage oss.StorageInterface, path string) string {
+	stream, err := storage.GetStream(path)
+	if err != nil {
+		t.Fatalf("No error should happen when get %v, but got %v", path, err)
+	}
+	defer stream.Close()
+
+	data, err := ioutil.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("No error should happen when read %v, but got %v", path, err)
+	}
+	return string(data)
+}
+
+func TestAll(t *testing.T) {
+	primary, secondary := memory.New(), memory.New()
+	mirror := New(Config{}, primary, secondary)
+	defer mirror.Close()
+
+	tests.TestAll(mirror, t)
+
+	if objects, _ := secondary.List("/"); len(objects) != 0 {
+		t.Errorf("Objects deleted from mirror should be deleted from secondary, but got %v", len(objects))
+	}
+}
+
+func TestWriteAll(t *testing.T) {
+	primary, secondary := memory.New(), memory.New()
+	mirror := New(Config{Mode: WriteAll}, primary, secondary)
+	defer mirror.Close()
+
+	// non-seekable readers are copied to a temporary file
+	reader := struct{ io.Reader }{strings.NewReader("sample")}
+	if _, err := mirror.Put("/sample.txt", reader); err != nil {
+		t.Fatalf("No error should happen when put sample file, but got %v", err)
+	}
+	for _, storage := range []oss.StorageInterface{primary, secondary} {
+		if got := content(t, storage, "/sample.txt"); got != "sample" {
+			t.Errorf("Sample file should be written to all storages, but got %v", got)
+		}
+	}
+
+	secondary.Failure = func(op, path string) error {
+		if op == "put" {
+			return errors.New("disk full")
+		}
+		return nil
+	}
+	if _, err := mirror.Put("/sample2.txt", strings.NewReader("sample2")); err == nil {
+		t.Errorf("Put should fail if secondary failed in WriteAll mode")
+	}
+}
+
+func TestWritePrimary(t *testing.T) {
+	var (
+		primary, secondary = memory.New(), memory.New()
+		mutex              sync.Mutex
+		failed             bool
+		repairErrs         []error
+	)
+
+	secondary.Failure = func(op, path string) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if op == "put" && !failed {
+			failed = true
+			return errors.New("disk full")
+		}
+		return nil
+	}
+
+	mirror := New(Config{Mode: WritePrimary, OnError: func(path string, err error) {
+		repairErrs = append(repairErrs, err)
+	}}, primary, secondary)
+
+	if _, err := mirror.Put("/sample.txt", strings.NewReader("sample")); err != nil {
+		t.Fatalf("Put should succeed once primary succeeded, but got %v", err)
+	}
+	mirror.Close()
+
+	if len(repairErrs) != 0 {
+		t.Errorf("No error should happen when repair, but got %v", repairErrs)
+	}
+	if got := content(t, secondary, "/sample.txt"); got != "sample" {
+		t.Errorf("Failed write should be repaired in secondary, but got %v", got)
+	}
+}
+
+func TestReadFallback(t *testing.T) {
+	primary, secondary := memory.New(), memory.New()
+	mirror := New(Config{}, primary, secondary)
+	defer mirror.Close()
+
+	mirror.Put("/sample.txt", strings.NewReader("sample"))
+	primary.Failure = func(op, path string) error {
+		return errors.New("unavailable")
+	}
+
+	if got := content(t, mirror, "/sample.txt"); got != "sample" {
+		t.Errorf("Read should fall back to secondary, but got %v", got)
+	}
+
+	primary.Failure = nil
+	secondary.Put("/deleted.txt", strings.NewReader("deleted"))
+	if _, err := mirror.GetStream("/deleted.txt"); !errors.Is(err, oss.ErrNotExist) {
+		t.Errorf("Read of object not in primary should not fall back, but got %v", err)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	primary, secondary := memory.New(), memory.New()
+	mirror := New(Config{}, primary, secondary)
+	defer mirror.Close()
+
+	primary.Put("/missing.txt", strings.NewReader("missing"))
+	primary.Put("/changed.txt", strings.NewReader("changed"))
+	secondary.Put("/changed.txt", strings.NewReader("old"))
+	secondary.Put("/deleted.txt", strings.NewReader("deleted"))
+
+	if err := mirror.Repair(""); err != nil {
+		t.Fatalf("No error should happen when repair, but got %v", err)
+	}
+
+	if got := content(t, secondary, "/missing.txt"); got != "missing" {
+		t.Errorf("Missing object should be copied to secondary, but got %v", got)
+	}
+	if got := content(t, secondary, "/changed.txt"); got != "changed" {
+		t.Errorf("Changed object should be copied to secondary, but got %v", got)
+	}
+	if exists, _ := oss.Exists(secondary, "/deleted.txt"); exists {
+		t.Errorf("Object not in primary should be deleted from secondary")
+	}
+}
+
+func TestRepairListFailure(t *testing.T) {
+	primary, failing, secondary := memory.New(), memory.New(), memory.New()
+	failing.Failure = func(op, path string) error {
+		if op == "list" {
+			return errors.New("list failed")
+		}
+		return nil
+	}
+	mirror := New(Config{}, primary, failing, secondary)
+	defer mirror.Close()
+
+	primary.Put("/uploads/missing.txt", strings.NewReader("missing"))
+
+	err := mirror.Repair("uploads/")
+	if batchErr, ok := err.(oss.BatchError); !ok || batchErr["uploads/"] == nil {
+		t.Errorf("Repair should report secondary failed to list, but got %#v", err)
+	}
+
+	if got := content(t, secondary, "/uploads/missing.txt"); got != "missing" {
+		t.Errorf("Secondaries after the one failed to list should be repaired, but got %v", got)
+	}
+}
@@ -0,0 +1,69 @@
+package oss
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// Opener initialize a storage from URL, the URL's scheme is the one it is registered with
+type Opener func(u *url.URL) (StorageInterface, error)
+
+var (
+	openersMutex sync.RWMutex
+	openers      = map[string]Opener{}
+)
+
+// Register make storage opener available by URL scheme, backend packages register themselves when imported, e.g.
+//
+//	import _ "github.com/qor/oss/s3"
+//
+// Register panics if opener is nil or the scheme is registered twice
+func Register(scheme string, opener Opener) {
+	openersMutex.Lock()
+	defer openersMutex.Unlock()
+
+	if opener == nil {
+		panic("oss: Register opener is nil")
+	}
+	if _, dup := openers[scheme]; dup {
+		panic("oss: Register called twice for scheme " + scheme)
+	}
+	openers[scheme] = opener
+}
+
+// Schemes returns sorted list of registered URL schemes
+func Schemes() []string {
+	openersMutex.RLock()
+	defer openersMutex.RUnlock()
+
+	schemes := make([]string, 0, len(openers))
+	for scheme := range openers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Open initialize storage from URL with the opener registered for its scheme, e.g.
+//
+//	oss.Open("s3://bucket?region=us-east-1&acl=private")
+//	oss.Open("file:///var/data")
+//
+// check backend's NewFromURL for supported parameters
+func Open(rawURL string) (StorageInterface, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	openersMutex.RLock()
+	opener, ok := openers[u.Scheme]
+	openersMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("oss: unknown scheme %q (forgotten import?), registered schemes: %v", u.Scheme, Schemes())
+	}
+	return opener(u)
+}
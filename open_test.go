@@ -0,0 +1,33 @@
+package oss_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/qor/oss"
+)
+
+func TestOpen(t *testing.T) {
+	oss.Register("plain", func(u *url.URL) (oss.StorageInterface, error) {
+		return plainStorage{content: map[string][]byte{u.Path: []byte(u.Host)}}, nil
+	})
+
+	storage, err := oss.Open("plain://sample/sample.txt")
+	if err != nil {
+		t.Fatalf("No error should happen when open registered scheme, but got %v", err)
+	}
+	if string(storage.(plainStorage).content["/sample.txt"]) != "sample" {
+		t.Errorf("Opener should be called with parsed URL")
+	}
+
+	if _, err := oss.Open("unknown://sample"); err == nil {
+		t.Errorf("There should be an error when open unknown scheme")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Register should panic when scheme registered twice")
+		}
+	}()
+	oss.Register("plain", func(u *url.URL) (oss.StorageInterface, error) { return nil, nil })
+}
@@ -19,9 +19,18 @@ type StorageInterface interface {
 
 // Object content object
 type Object struct {
-	Path             string
-	Name             string
-	LastModified     *time.Time
+	Path         string
+	Name         string
+	LastModified *time.Time
+	Size         int64
+	// ETag identifies object's content, it changes when the content changes, the format is storage specific
+	ETag         string
+	ContentType  string
+	StorageClass string
+	// Checksum checksums of the content computed while putting the object, nil for objects got in other ways
+	Checksum *Checksum
+	// Metadata user defined metadata of the object
+	Metadata         map[string]string
 	StorageInterface StorageInterface
 }
 
@@ -0,0 +1,300 @@
+package oss
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// WithPrefix confine storage to objects under prefix, e.g. "tenants/42", paths are prefixed on the way in and
+// the prefix is stripped from paths of returned objects, common prefixes and errors. Paths containing ".."
+// segments are rejected with ErrInvalidPath, so they can't escape the prefix, the same for prefixes containing
+// them. URLs of GetURL are generated by storage with prefixed paths, so public and presigned URLs keep working
+func WithPrefix(storage StorageInterface, prefix string) ContextStorage {
+	prefixed := &prefixedStorage{storage: storage, prefix: strings.Trim(prefix, "/")}
+	if hasDotDot(prefixed.prefix) {
+		prefixed.err = fmt.Errorf("%w: prefix %q", ErrInvalidPath, prefix)
+	}
+	return prefixed
+}
+
+var (
+	_ ContextStorage = (*prefixedStorage)(nil)
+	_ Stater         = (*prefixedStorage)(nil)
+	_ PageLister     = (*prefixedStorage)(nil)
+	_ OptionsPutter  = (*prefixedStorage)(nil)
+	_ Copier         = (*prefixedStorage)(nil)
+	_ Mover          = (*prefixedStorage)(nil)
+	_ RangeGetter    = (*prefixedStorage)(nil)
+	_ BatchDeleter   = (*prefixedStorage)(nil)
+)
+
+type prefixedStorage struct {
+	storage StorageInterface
+	prefix  string
+	// err error of invalid prefix, returned by all operations
+	err error
+}
+
+func hasDotDot(path string) bool {
+	for _, segment := range strings.Split(path, "/") {
+		if segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// join prefix path, leading "/" of path is kept, as storages treat paths with or without it differently in some cases
+func (prefixed *prefixedStorage) join(op, path string) (string, error) {
+	if prefixed.err != nil {
+		return "", NewError(op, path, 0, prefixed.err)
+	}
+	if hasDotDot(path) {
+		return "", NewError(op, path, 0, ErrInvalidPath)
+	}
+
+	if prefixed.prefix == "" {
+		return path, nil
+	}
+
+	joined := prefixed.prefix + "/" + strings.TrimLeft(path, "/")
+	if strings.HasPrefix(path, "/") {
+		joined = "/" + joined
+	}
+	return joined, nil
+}
+
+// strip remove prefix from path, leading "/" of path is kept, paths not under prefix are returned as is
+func (prefixed *prefixedStorage) strip(path string) string {
+	if prefixed.prefix == "" {
+		return path
+	}
+
+	rest := strings.TrimLeft(path, "/")
+	if !strings.HasPrefix(rest, prefixed.prefix+"/") {
+		return path
+	}
+
+	rest = strings.TrimPrefix(rest, prefixed.prefix+"/")
+	if strings.HasPrefix(path, "/") {
+		return "/" + rest
+	}
+	return rest
+}
+
+// own strip prefix from object's path and make its methods go through the prefixed storage
+func (prefixed *prefixedStorage) own(object *Object) *Object {
+	if object != nil {
+		object.Path = prefixed.strip(object.Path)
+		object.StorageInterface = prefixed
+	}
+	return object
+}
+
+// error strip prefix from path of err, so the prefix isn't exposed to users confined to it
+func (prefixed *prefixedStorage) error(err error) error {
+	if ossErr, ok := err.(*Error); ok {
+		stripped := *ossErr
+		stripped.Path = prefixed.strip(ossErr.Path)
+		return &stripped
+	}
+	return err
+}
+
+func (prefixed *prefixedStorage) Get(path string) (*os.File, error) {
+	return prefixed.GetCtx(context.Background(), path)
+}
+
+func (prefixed *prefixedStorage) GetCtx(ctx context.Context, path string) (*os.File, error) {
+	joined, err := prefixed.join("get", path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := WithContext(prefixed.storage).GetCtx(ctx, joined)
+	return file, prefixed.error(err)
+}
+
+func (prefixed *prefixedStorage) GetStream(path string) (io.ReadCloser, error) {
+	return prefixed.GetStreamCtx(context.Background(), path)
+}
+
+func (prefixed *prefixedStorage) GetStreamCtx(ctx context.Context, path string) (io.ReadCloser, error) {
+	joined, err := prefixed.join("get", path)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := WithContext(prefixed.storage).GetStreamCtx(ctx, joined)
+	return stream, prefixed.error(err)
+}
+
+func (prefixed *prefixedStorage) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	joined, err := prefixed.join("get", path)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := GetRange(prefixed.storage, joined, offset, length)
+	return stream, prefixed.error(err)
+}
+
+func (prefixed *prefixedStorage) Put(path string, reader io.Reader) (*Object, error) {
+	return prefixed.PutCtx(context.Background(), path, reader)
+}
+
+func (prefixed *prefixedStorage) PutCtx(ctx context.Context, path string, reader io.Reader) (*Object, error) {
+	joined, err := prefixed.join("put", path)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := WithContext(prefixed.storage).PutCtx(ctx, joined, reader)
+	return prefixed.own(object), prefixed.error(err)
+}
+
+func (prefixed *prefixedStorage) PutWithOptions(path string, reader io.Reader, options *PutOptions) (*Object, error) {
+	joined, err := prefixed.join("put", path)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := PutWithOptions(prefixed.storage, joined, reader, options)
+	return prefixed.own(object), prefixed.error(err)
+}
+
+func (prefixed *prefixedStorage) Stat(path string) (*Object, error) {
+	joined, err := prefixed.join("stat", path)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := Stat(prefixed.storage, joined)
+	return prefixed.own(object), prefixed.error(err)
+}
+
+func (prefixed *prefixedStorage) Delete(path string) error {
+	return prefixed.DeleteCtx(context.Background(), path)
+}
+
+func (prefixed *prefixedStorage) DeleteCtx(ctx context.Context, path string) error {
+	joined, err := prefixed.join("delete", path)
+	if err != nil {
+		return err
+	}
+	return prefixed.error(WithContext(prefixed.storage).DeleteCtx(ctx, joined))
+}
+
+func (prefixed *prefixedStorage) DeleteMany(paths []string) map[string]error {
+	var (
+		errs   = map[string]error{}
+		joined = make([]string, 0, len(paths))
+		origin = map[string]string{}
+	)
+
+	for _, path := range paths {
+		p, err := prefixed.join("delete", path)
+		if err != nil {
+			errs[path] = err
+			continue
+		}
+		joined = append(joined, p)
+		origin[p] = path
+	}
+
+	if len(joined) > 0 {
+		for p, err := range DeleteMany(prefixed.storage, joined) {
+			errs[origin[p]] = prefixed.error(err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (prefixed *prefixedStorage) Copy(src, dst string) error {
+	joinedSrc, err := prefixed.join("copy", src)
+	if err != nil {
+		return err
+	}
+	joinedDst, err := prefixed.join("copy", dst)
+	if err != nil {
+		return err
+	}
+	return prefixed.error(Copy(prefixed.storage, joinedSrc, joinedDst))
+}
+
+func (prefixed *prefixedStorage) Move(src, dst string) error {
+	joinedSrc, err := prefixed.join("move", src)
+	if err != nil {
+		return err
+	}
+	joinedDst, err := prefixed.join("move", dst)
+	if err != nil {
+		return err
+	}
+	return prefixed.error(Move(prefixed.storage, joinedSrc, joinedDst))
+}
+
+func (prefixed *prefixedStorage) List(path string) ([]*Object, error) {
+	return prefixed.ListCtx(context.Background(), path)
+}
+
+func (prefixed *prefixedStorage) ListCtx(ctx context.Context, path string) ([]*Object, error) {
+	joined, err := prefixed.join("list", path)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := WithContext(prefixed.storage).ListCtx(ctx, joined)
+	for _, object := range objects {
+		prefixed.own(object)
+	}
+	return objects, prefixed.error(err)
+}
+
+func (prefixed *prefixedStorage) ListPage(options ListOptions) (*ListResult, error) {
+	var err error
+	if options.Prefix, err = prefixed.join("list", options.Prefix); err != nil {
+		return nil, err
+	}
+	if options.StartAfter != "" {
+		if options.StartAfter, err = prefixed.join("list", options.StartAfter); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := ListPage(prefixed.storage, options)
+	if result != nil {
+		for _, object := range result.Objects {
+			prefixed.own(object)
+		}
+		for i, commonPrefix := range result.CommonPrefixes {
+			result.CommonPrefixes[i] = prefixed.strip(commonPrefix)
+		}
+	}
+	return result, prefixed.error(err)
+}
+
+func (prefixed *prefixedStorage) GetURL(path string) (string, error) {
+	return prefixed.GetURLCtx(context.Background(), path)
+}
+
+func (prefixed *prefixedStorage) GetURLCtx(ctx context.Context, path string) (string, error) {
+	joined, err := prefixed.join("url", path)
+	if err != nil {
+		return "", err
+	}
+
+	url, err := WithContext(prefixed.storage).GetURLCtx(ctx, joined)
+	return url, prefixed.error(err)
+}
+
+func (prefixed *prefixedStorage) GetEndpoint() string {
+	return prefixed.storage.GetEndpoint()
+}
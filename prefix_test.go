@@ -0,0 +1,102 @@
+package oss_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/qor/oss"
+	"github.com/qor/oss/memory"
+)
+
+func TestWithPrefix(t *testing.T) {
+	var (
+		storage = memory.New()
+		tenant1 = oss.WithPrefix(storage, "/tenants/1/")
+		tenant2 = oss.WithPrefix(storage, "tenants/2")
+	)
+
+	object, err := tenant1.Put("/sample.txt", strings.NewReader("tenant1"))
+	if err != nil {
+		t.Fatalf("No error should happen when put, but got %v", err)
+	}
+	if object.Path != "/sample.txt" {
+		t.Errorf("Object's path should be stripped the prefix, but got %v", object.Path)
+	}
+	if _, err := tenant2.Put("/sample.txt", strings.NewReader("tenant2")); err != nil {
+		t.Fatalf("No error should happen when put, but got %v", err)
+	}
+
+	stream, err := storage.GetStream("/tenants/1/sample.txt")
+	if err != nil {
+		t.Fatalf("File should be saved under the prefix, but got %v", err)
+	}
+	if content, _ := ioutil.ReadAll(stream); string(content) != "tenant1" {
+		t.Errorf("File under prefix should be tenant1, but got %v", string(content))
+	}
+
+	if content, err := object.Get(); err != nil {
+		t.Errorf("Object should be got from prefixed storage, but got %v", err)
+	} else if data, _ := ioutil.ReadAll(content); string(data) != "tenant1" {
+		t.Errorf("Object should be got from prefixed storage, but got %v", string(data))
+	}
+
+	objects, err := tenant2.List("/")
+	if err != nil || len(objects) != 1 || objects[0].Path != "/sample.txt" {
+		t.Errorf("Prefixed storage should list its objects only, but got %v, %v", objects, err)
+	}
+
+	result, err := oss.ListPage(tenant1, oss.ListOptions{Delimiter: "/"})
+	if err != nil || len(result.Objects) != 1 || result.Objects[0].Path != "/sample.txt" {
+		t.Errorf("Prefixed storage should list one page of its objects only, but got %v, %v", result, err)
+	}
+
+	if url, err := tenant1.GetURL("/sample.txt"); err != nil || url != "/tenants/1/sample.txt" {
+		t.Errorf("URL should be generated with the prefix, but got %v, %v", url, err)
+	}
+
+	if _, err := oss.Stat(tenant1, "/missing.txt"); !errors.Is(err, oss.ErrNotExist) {
+		t.Errorf("Stat missing file should return ErrNotExist, but got %v", err)
+	} else if ossErr, ok := err.(*oss.Error); !ok || ossErr.Path != "/missing.txt" {
+		t.Errorf("Error's path should be stripped the prefix, but got %v", err)
+	}
+
+	errs := oss.DeleteMany(tenant1, []string{"/sample.txt", "/../2/sample.txt"})
+	if len(errs) != 1 || !errors.Is(errs["/../2/sample.txt"], oss.ErrInvalidPath) {
+		t.Errorf("Delete paths escaping the prefix should fail with ErrInvalidPath, but got %v", errs)
+	}
+	if exists, _ := oss.Exists(storage, "/tenants/1/sample.txt"); exists {
+		t.Errorf("File of tenant1 should be deleted")
+	}
+	if exists, _ := oss.Exists(storage, "/tenants/2/sample.txt"); !exists {
+		t.Errorf("File of tenant2 should not be deleted")
+	}
+}
+
+func TestWithPrefixEscape(t *testing.T) {
+	storage := memory.New()
+	storage.Put("/secret.txt", strings.NewReader("secret"))
+
+	tenant := oss.WithPrefix(storage, "tenants/1")
+	for _, path := range []string{"../../secret.txt", "/a/../../../secret.txt", ".."} {
+		if _, err := tenant.GetStream(path); !errors.Is(err, oss.ErrInvalidPath) {
+			t.Errorf("Get %v should fail with ErrInvalidPath, but got %v", path, err)
+		}
+		if _, err := tenant.Put(path, strings.NewReader("sample")); !errors.Is(err, oss.ErrInvalidPath) {
+			t.Errorf("Put %v should fail with ErrInvalidPath, but got %v", path, err)
+		}
+	}
+
+	if err := oss.Copy(tenant, "/../../secret.txt", "/copied.txt"); !errors.Is(err, oss.ErrInvalidPath) {
+		t.Errorf("Copy from outside of prefix should fail with ErrInvalidPath, but got %v", err)
+	}
+
+	if _, err := oss.ListPage(tenant, oss.ListOptions{Prefix: "../"}); !errors.Is(err, oss.ErrInvalidPath) {
+		t.Errorf("List outside of prefix should fail with ErrInvalidPath, but got %v", err)
+	}
+
+	if _, err := oss.WithPrefix(storage, "tenants/../..").Put("/sample.txt", strings.NewReader("sample")); !errors.Is(err, oss.ErrInvalidPath) {
+		t.Errorf("Prefix containing .. should fail with ErrInvalidPath, but got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package oss
+
+import "io"
+
+// PutOptions options of an upload, empty fields fall back to storage's defaults,
+// e.g. content type guessed from the extension and ACL from storage's config
+type PutOptions struct {
+	ContentType        string
+	ContentDisposition string
+	CacheControl       string
+	ContentEncoding    string
+	// ACL canned ACL of the object, e.g. "private", "public-read"
+	ACL string
+	// Metadata user defined metadata, keys should be lower case
+	Metadata map[string]string
+}
+
+// OptionsPutter is implemented by storages that could store objects with PutOptions. Storages
+// ignore the fields they can't store, check backend's PutWithOptions for what is supported
+type OptionsPutter interface {
+	PutWithOptions(path string, reader io.Reader, options *PutOptions) (*Object, error)
+}
+
+// PutWithOptions store a reader into given path with storage's PutWithOptions, storages don't
+// implement OptionsPutter fall back to Put, and all options are ignored
+func PutWithOptions(storage StorageInterface, path string, reader io.Reader, options *PutOptions) (*Object, error) {
+	if putter, ok := storage.(OptionsPutter); ok {
+		return putter.PutWithOptions(path, reader, options)
+	}
+	return storage.Put(path, reader)
+}
@@ -0,0 +1,20 @@
+package oss_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/qor/oss"
+)
+
+func TestPutWithOptions(t *testing.T) {
+	storage := plainStorage{content: map[string][]byte{}}
+
+	if _, err := oss.PutWithOptions(storage, "/sample.txt", strings.NewReader("sample"), &oss.PutOptions{ContentType: "text/plain"}); err != nil {
+		t.Errorf("No error should happen when put with options, but got %v", err)
+	}
+
+	if string(storage.content["/sample.txt"]) != "sample" {
+		t.Errorf("Storages without options support should fall back to Put, but got %v", string(storage.content["/sample.txt"]))
+	}
+}
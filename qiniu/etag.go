@@ -0,0 +1,88 @@
+package qiniu
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"hash"
+	"io"
+)
+
+// etagBlockSize size of blocks hashed separately by Qiniu's etag
+const etagBlockSize = 4 * 1024 * 1024
+
+// etagHash computes Qiniu's etag, which is SHA-1 of the content prefixed with 0x16 if it isn't larger than 4MB,
+// otherwise SHA-1 of SHA-1s of each 4MB block prefixed with 0x96
+type etagHash struct {
+	block  hash.Hash
+	n      int
+	blocks []byte
+}
+
+func newETagHash() hash.Hash {
+	return &etagHash{block: sha1.New()}
+}
+
+func (h *etagHash) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		size := etagBlockSize - h.n
+		if size > len(p) {
+			size = len(p)
+		}
+		h.block.Write(p[:size])
+		h.n += size
+		p = p[size:]
+
+		if h.n == etagBlockSize {
+			h.blocks = h.block.Sum(h.blocks)
+			h.block.Reset()
+			h.n = 0
+		}
+	}
+	return written, nil
+}
+
+func (h *etagHash) Sum(b []byte) []byte {
+	blocks := h.blocks
+	if h.n > 0 || len(blocks) == 0 {
+		blocks = h.block.Sum(append([]byte{}, blocks...))
+	}
+
+	if len(blocks) == sha1.Size {
+		return append(append(b, 0x16), blocks...)
+	}
+	sum := sha1.Sum(blocks)
+	return append(append(b, 0x96), sum[:]...)
+}
+
+func (h *etagHash) Reset() {
+	h.block.Reset()
+	h.n = 0
+	h.blocks = nil
+}
+
+func (h *etagHash) Size() int {
+	return sha1.Size + 1
+}
+
+func (h *etagHash) BlockSize() int {
+	return h.block.BlockSize()
+}
+
+// ETag compute Qiniu's etag of content read from reader, e.g. to compare local files with ETag of objects
+func ETag(reader io.Reader) (string, error) {
+	h := newETagHash()
+	if _, err := io.Copy(h, reader); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// parseETag decode Qiniu's etag, returns nil if it isn't one
+func parseETag(etag string) []byte {
+	sum, err := base64.URLEncoding.DecodeString(etag)
+	if err != nil || len(sum) != sha1.Size+1 || (sum[0] != 0x16 && sum[0] != 0x96) {
+		return nil
+	}
+	return sum
+}
@@ -1,9 +1,18 @@
 package qiniu_test
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/jinzhu/configor"
+	"github.com/qor/oss"
 	"github.com/qor/oss/qiniu"
 	"github.com/qor/oss/tests"
 )
@@ -60,3 +69,48 @@ func TestAll(t *testing.T) {
 		tests.TestAll(cli, t)
 	}
 }
+
+func TestETag(t *testing.T) {
+	block := bytes.Repeat([]byte("s"), 4*1024*1024)
+	blockSum := sha1.Sum(block)
+	blocksSum := sha1.Sum(append(append([]byte{}, blockSum[:]...), blockSum[:]...))
+
+	for content, expected := range map[string][]byte{
+		"":                            {0x16, 0xda, 0x39, 0xa3, 0xee, 0x5e, 0x6b, 0x4b, 0x0d, 0x32, 0x55, 0xbf, 0xef, 0x95, 0x60, 0x18, 0x90, 0xaf, 0xd8, 0x07, 0x09},
+		string(block):                 append([]byte{0x16}, blockSum[:]...),
+		string(block) + string(block): append([]byte{0x96}, blocksSum[:]...),
+	} {
+		if etag, err := qiniu.ETag(strings.NewReader(content)); err != nil || etag != base64.URLEncoding.EncodeToString(expected) {
+			t.Errorf("ETag of %v bytes should be %v, but got %v, %v", len(content), base64.URLEncoding.EncodeToString(expected), etag, err)
+		}
+	}
+}
+
+func TestGetStreamChecksum(t *testing.T) {
+	content := []byte("sample content")
+	etag, _ := qiniu.ETag(bytes.NewReader(content))
+
+	for _, corrupt := range []bool{false, true} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body := append([]byte{}, content...)
+			if corrupt {
+				body[0] ^= 1
+			}
+			w.Header().Set("ETag", `"`+etag+`"`)
+			w.Write(body)
+		}))
+
+		client := qiniu.New(&qiniu.Config{Region: "huadong", Bucket: "bucket", Endpoint: server.URL})
+		stream, err := client.GetStream("/sample.txt")
+		if err != nil {
+			t.Fatalf("No error should happen when get object, but got %v", err)
+		}
+		_, err = ioutil.ReadAll(stream)
+		stream.Close()
+
+		if corrupt != errors.Is(err, oss.ErrChecksumMismatch) {
+			t.Errorf("Reading corrupted object should fail with oss.ErrChecksumMismatch, corrupted: %v, got %v", corrupt, err)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,184 @@
+package oss
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// RangeGetter is implemented by storages that could read part of an object without downloading all of it
+type RangeGetter interface {
+	// GetRange get length bytes of object starting at offset, a negative length reads to the end of the object
+	GetRange(path string, offset, length int64) (io.ReadCloser, error)
+}
+
+// GetRange get length bytes of object starting at offset with storage's GetRange, a negative length reads
+// to the end of the object. Storages don't implement RangeGetter fall back to streaming the object and
+// skipping bytes before offset
+func GetRange(storage StorageInterface, path string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("oss: negative offset %d", offset)
+	}
+
+	if length == 0 {
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}
+
+	if getter, ok := storage.(RangeGetter); ok {
+		return getter.GetRange(path, offset, length)
+	}
+
+	stream, err := storage.GetStream(path)
+	if err != nil {
+		return nil, err
+	}
+	return LimitRange(stream, offset, length)
+}
+
+// RangeHeader format HTTP Range header to request length bytes starting at offset, a negative length requests to the end
+func RangeHeader(offset, length int64) string {
+	if length < 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
+
+// LimitRange skip offset bytes of a full object stream and limit it to length bytes, a negative length reads to the end.
+// It is used for storages, or servers ignoring Range header, that return the whole object
+func LimitRange(stream io.ReadCloser, offset, length int64) (io.ReadCloser, error) {
+	if _, err := io.CopyN(ioutil.Discard, stream, offset); err != nil && err != io.EOF {
+		stream.Close()
+		return nil, err
+	}
+
+	if length < 0 {
+		return stream, nil
+	}
+	return readCloser{Reader: io.LimitReader(stream, length), Closer: stream}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// ObjectReader reads an object with range requests, it implements io.ReaderAt and io.ReadSeeker,
+// so it could be used with http.ServeContent and zip.NewReader without downloading the whole object
+type ObjectReader struct {
+	storage StorageInterface
+	path    string
+	size    int64
+	offset  int64
+	stream  io.ReadCloser
+}
+
+// OpenReaderAt open object at path for random access, the object's size is got with Stat, storages
+// don't implement Stater need to read the whole object once to count its size
+func OpenReaderAt(storage StorageInterface, path string) (*ObjectReader, error) {
+	reader := &ObjectReader{storage: storage, path: path}
+
+	if stater, ok := storage.(Stater); ok {
+		object, err := stater.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		reader.size = object.Size
+		return reader, nil
+	}
+
+	stream, err := storage.GetStream(path)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	if reader.size, err = io.Copy(ioutil.Discard, stream); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// Size size of the object
+func (reader *ObjectReader) Size() int64 {
+	return reader.size
+}
+
+// ReadAt read len(p) bytes starting at offset off with a range request
+func (reader *ObjectReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("oss: negative offset")
+	}
+
+	if off >= reader.size {
+		return 0, io.EOF
+	}
+
+	length := int64(len(p))
+	if off+length > reader.size {
+		length = reader.size - off
+	}
+
+	stream, err := GetRange(reader.storage, reader.path, off, length)
+	if err != nil {
+		return 0, err
+	}
+	defer stream.Close()
+
+	n, err := io.ReadFull(stream, p[:length])
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Read read from current offset, the range request is kept open between reads until Seek moves the offset
+func (reader *ObjectReader) Read(p []byte) (int, error) {
+	if reader.offset >= reader.size {
+		return 0, io.EOF
+	}
+
+	if reader.stream == nil {
+		stream, err := GetRange(reader.storage, reader.path, reader.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		reader.stream = stream
+	}
+
+	n, err := reader.stream.Read(p)
+	reader.offset += int64(n)
+	return n, err
+}
+
+// Seek set offset of next Read
+func (reader *ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += reader.offset
+	case io.SeekEnd:
+		offset += reader.size
+	}
+
+	if offset < 0 {
+		return 0, errors.New("oss: negative position")
+	}
+
+	if offset != reader.offset && reader.stream != nil {
+		reader.stream.Close()
+		reader.stream = nil
+	}
+	reader.offset = offset
+	return offset, nil
+}
+
+// Close close the stream opened by Read
+func (reader *ObjectReader) Close() error {
+	if reader.stream == nil {
+		return nil
+	}
+	err := reader.stream.Close()
+	reader.stream = nil
+	return err
+}
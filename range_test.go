@@ -0,0 +1,79 @@
+package oss_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/qor/oss"
+)
+
+func TestGetRange(t *testing.T) {
+	storage := plainStorage{content: map[string][]byte{"/sample.txt": []byte("0123456789")}}
+
+	for _, c := range []struct {
+		offset, length int64
+		expected       string
+	}{
+		{0, 3, "012"},
+		{4, 2, "45"},
+		{7, -1, "789"},
+		{8, 5, "89"},
+		{3, 0, ""},
+	} {
+		stream, err := oss.GetRange(storage, "/sample.txt", c.offset, c.length)
+		if err != nil {
+			t.Errorf("No error should happen when get range %v, %v, but got %v", c.offset, c.length, err)
+			continue
+		}
+
+		if content, _ := ioutil.ReadAll(stream); string(content) != c.expected {
+			t.Errorf("Range %v, %v should be %v, but got %v", c.offset, c.length, c.expected, string(content))
+		}
+		stream.Close()
+	}
+}
+
+func TestOpenReaderAt(t *testing.T) {
+	storage := plainStorage{content: map[string][]byte{"/sample.txt": []byte("0123456789")}}
+
+	reader, err := oss.OpenReaderAt(storage, "/sample.txt")
+	if err != nil {
+		t.Fatalf("No error should happen when open reader, but got %v", err)
+	}
+	defer reader.Close()
+
+	if reader.Size() != 10 {
+		t.Errorf("Size should be 10, but got %v", reader.Size())
+	}
+
+	p := make([]byte, 4)
+	if n, err := reader.ReadAt(p, 3); err != nil || string(p[:n]) != "3456" {
+		t.Errorf("ReadAt should return 3456, but got %v, %v", string(p[:n]), err)
+	}
+
+	if n, err := reader.ReadAt(p, 8); err != io.EOF || string(p[:n]) != "89" {
+		t.Errorf("ReadAt near the end should return 89 with io.EOF, but got %v, %v", string(p[:n]), err)
+	}
+
+	if _, err := reader.Seek(-3, io.SeekEnd); err != nil {
+		t.Errorf("No error should happen when seek, but got %v", err)
+	}
+	if content, _ := ioutil.ReadAll(reader); string(content) != "789" {
+		t.Errorf("Read after seek should return 789, but got %v", string(content))
+	}
+
+	// serve range request
+	reader.Seek(0, io.SeekStart)
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/sample.txt", nil)
+	request.Header.Set("Range", "bytes=2-4")
+	http.ServeContent(recorder, request, "sample.txt", time.Now(), reader)
+
+	if recorder.Code != http.StatusPartialContent || recorder.Body.String() != "234" {
+		t.Errorf("ServeContent should respond 234 with 206, but got %v with %v", recorder.Body.String(), recorder.Code)
+	}
+}
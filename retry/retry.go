@@ -0,0 +1,182 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/qor/oss"
+)
+
+// Config retry config
+type Config struct {
+	// Attempts max number of attempts including the first one, default is 3
+	Attempts int
+	// BaseDelay delay before the first retry, it doubles on each retry, default is 100ms
+	BaseDelay time.Duration
+	// MaxDelay upper bound of delay between attempts, default is 5s
+	MaxDelay time.Duration
+	// Retryable reports whether operation failed with err should be retried, default is IsRetryable
+	Retryable func(err error) bool
+}
+
+func (config Config) withDefaults() Config {
+	if config.Attempts <= 0 {
+		config.Attempts = 3
+	}
+	if config.BaseDelay <= 0 {
+		config.BaseDelay = 100 * time.Millisecond
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = 5 * time.Second
+	}
+	if config.Retryable == nil {
+		config.Retryable = IsRetryable
+	}
+	return config
+}
+
+// New wrap storage to retry operations failed with transient errors
+func New(storage oss.StorageInterface, config Config) oss.ContextStorage {
+	return oss.Wrap(storage, Middleware(config))
+}
+
+// Middleware retry operations failed with retryable errors with jittered exponential backoff. Put bodies are
+// rewound before each retry if they are io.Seeker, uploads of other readers are never retried as the body
+// has been consumed. Streams returned by GetStream and GetRange are not retried once they are returned
+func Middleware(config Config) oss.Middleware {
+	config = config.withDefaults()
+
+	return func(call *oss.Call, next func() error) error {
+		var (
+			seeker io.Seeker
+			offset int64
+		)
+
+		if call.Body != nil {
+			var ok bool
+			if seeker, ok = call.Body.(io.Seeker); ok {
+				var err error
+				if offset, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+					seeker = nil
+				}
+			}
+		}
+
+		ctx := call.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		for attempt := 1; ; attempt++ {
+			err := next()
+			if err == nil || attempt >= config.Attempts || !config.Retryable(err) {
+				return err
+			}
+
+			if call.Body != nil {
+				if seeker == nil {
+					return err
+				}
+				if _, seekErr := seeker.Seek(offset, io.SeekStart); seekErr != nil {
+					return err
+				}
+			}
+
+			if sleepErr := sleep(ctx, backoff(config, attempt)); sleepErr != nil {
+				return err
+			}
+		}
+	}
+}
+
+// backoff delay before retry after attempt, it is a random duration in [delay/2, delay) where delay
+// is BaseDelay doubled for each previous attempt, capped by MaxDelay
+func backoff(config Config, attempt int) time.Duration {
+	delay := config.BaseDelay
+	for i := 1; i < attempt && delay < config.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > config.MaxDelay {
+		delay = config.MaxDelay
+	}
+
+	half := int64(delay / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// IsRetryable reports whether err is a transient failure: request timeouts, throttling, server errors (5xx),
+// network timeouts, refused or reset connections, and unexpected EOF. Errors of canceled contexts and classified
+// errors (not exist, permission, etc.) are never retryable
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var ossErr *oss.Error
+	if errors.As(err, &ossErr) {
+		if ossErr.Kind != nil {
+			return false
+		}
+		if ossErr.StatusCode != 0 {
+			return retryableStatus(ossErr.StatusCode)
+		}
+	}
+
+	for ; err != nil; err = unwrap(err) {
+		if err == io.ErrUnexpectedEOF || err == syscall.ECONNRESET || err == syscall.ECONNREFUSED || err == syscall.EPIPE {
+			return true
+		}
+
+		switch e := err.(type) {
+		case net.Error:
+			if e.Timeout() {
+				return true
+			}
+			if _, ok := err.(*net.OpError); ok {
+				return true
+			}
+		case interface{ StatusCode() int }: // aws-sdk-go awserr.RequestFailure
+			return retryableStatus(e.StatusCode())
+		case interface{ HttpCode() int }: // qiniu
+			return retryableStatus(e.HttpCode())
+		case interface{ Temporary() bool }:
+			if e.Temporary() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func retryableStatus(statusCode int) bool {
+	return statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// unwrap unwrap err with Unwrap, or OrigErr of aws-sdk-go errors
+func unwrap(err error) error {
+	if e := errors.Unwrap(err); e != nil {
+		return e
+	}
+	if e, ok := err.(interface{ OrigErr() error }); ok {
+		return e.OrigErr()
+	}
+	return nil
+}
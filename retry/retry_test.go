@@ -0,0 +1,149 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/qor/oss"
+	"github.com/qor/oss/memory"
+	"github.com/qor/oss/tests"
+)
+
+var config = Config{BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// failTimes make storage's op fail with err for first n calls
+func failTimes(storage *memory.Memory, op string, n int, err error) *int {
+	var calls int
+	storage.Failure = func(o, path string) error {
+		if o != op {
+			return nil
+		}
+		if calls++; calls <= n {
+			return err
+		}
+		return nil
+	}
+	return &calls
+}
+
+func TestAll(t *testing.T) {
+	tests.TestAll(New(memory.New(), config), t)
+}
+
+func TestRetry(t *testing.T) {
+	storage := memory.New()
+	calls := failTimes(storage, "put", 2, oss.NewError("put", "/sample.txt", http.StatusServiceUnavailable, errors.New("slow down")))
+
+	reader := strings.NewReader("sample")
+	if _, err := New(storage, config).Put("/sample.txt", reader); err != nil {
+		t.Fatalf("No error should happen when put succeeds on third attempt, but got %v", err)
+	}
+	if *calls != 3 {
+		t.Errorf("Put should be attempted 3 times, but got %v", *calls)
+	}
+
+	stream, err := storage.GetStream("/sample.txt")
+	if err != nil {
+		t.Fatalf("No error should happen when get sample file, but got %v", err)
+	}
+	defer stream.Close()
+	if content, _ := ioutil.ReadAll(stream); string(content) != "sample" {
+		t.Errorf("Retried put should upload whole body, but got %v", string(content))
+	}
+}
+
+func TestRetryAttempts(t *testing.T) {
+	storage := memory.New()
+	calls := failTimes(storage, "stat", 10, syscall.ECONNRESET)
+
+	if _, err := oss.Stat(New(storage, Config{Attempts: 4, BaseDelay: time.Millisecond}), "/sample.txt"); !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("Last error should be returned after all attempts failed, but got %v", err)
+	}
+	if *calls != 4 {
+		t.Errorf("Stat should be attempted 4 times, but got %v", *calls)
+	}
+}
+
+func TestNonRetryable(t *testing.T) {
+	storage := memory.New()
+	calls := failTimes(storage, "get", 10, oss.ErrPermission)
+
+	if _, err := New(storage, config).GetStream("/sample.txt"); !errors.Is(err, oss.ErrPermission) {
+		t.Errorf("Permission error should be returned, but got %v", err)
+	}
+	if *calls != 1 {
+		t.Errorf("Non-retryable error should not be retried, but attempted %v times", *calls)
+	}
+
+	if _, err := oss.Stat(New(memory.New(), config), "/missing.txt"); !errors.Is(err, oss.ErrNotExist) {
+		t.Errorf("Missing object should fail with oss.ErrNotExist, but got %v", err)
+	}
+}
+
+func TestNonSeekableBody(t *testing.T) {
+	storage := memory.New()
+	calls := failTimes(storage, "put", 1, io.ErrUnexpectedEOF)
+
+	reader := struct{ io.Reader }{strings.NewReader("sample")}
+	if _, err := New(storage, config).Put("/sample.txt", reader); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Put of non-seekable body should not be retried, but got %v", err)
+	}
+	if *calls != 1 {
+		t.Errorf("Put of non-seekable body should be attempted once, but got %v", *calls)
+	}
+}
+
+func TestContextCanceled(t *testing.T) {
+	storage := memory.New()
+	calls := failTimes(storage, "delete", 10, syscall.ECONNREFUSED)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	wrapped := New(storage, Config{Attempts: 100, BaseDelay: 50 * time.Millisecond})
+	if err := wrapped.DeleteCtx(ctx, "/sample.txt"); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("Last error should be returned when context is done, but got %v", err)
+	}
+	if *calls != 1 {
+		t.Errorf("Delete should not be retried after context is done, but attempted %v times", *calls)
+	}
+}
+
+type httpCodeError int
+
+func (e httpCodeError) Error() string { return fmt.Sprintf("status %d", int(e)) }
+func (e httpCodeError) HttpCode() int { return int(e) }
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		err       error
+		retryable bool
+	}{
+		{nil, false},
+		{errors.New("invalid argument"), false},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, false},
+		{io.ErrUnexpectedEOF, true},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{oss.NewError("get", "/a", http.StatusInternalServerError, errors.New("internal")), true},
+		{oss.NewError("get", "/a", http.StatusTooManyRequests, errors.New("throttled")), true},
+		{oss.NewError("get", "/a", http.StatusBadRequest, errors.New("bad request")), false},
+		{oss.NewError("get", "/a", http.StatusNotFound, errors.New("not found")), false},
+		{oss.NewError("get", "/a", 0, httpCodeError(502)), true},
+		{oss.NewError("get", "/a", 0, httpCodeError(400)), false},
+	}
+
+	for _, c := range cases {
+		if got := IsRetryable(c.err); got != c.retryable {
+			t.Errorf("IsRetryable(%v) should be %v, but got %v", c.err, c.retryable, got)
+		}
+	}
+}
@@ -2,6 +2,9 @@ package s3
 
 import (
 	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -12,19 +15,33 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/qor/oss"
 )
 
+var (
+	_ oss.ContextStorage = (*Client)(nil)
+	_ oss.Stater         = (*Client)(nil)
+	_ oss.PageLister     = (*Client)(nil)
+	_ oss.OptionsPutter  = (*Client)(nil)
+	_ oss.Copier         = (*Client)(nil)
+	_ oss.Mover          = (*Client)(nil)
+	_ oss.RangeGetter    = (*Client)(nil)
+	_ oss.BatchDeleter   = (*Client)(nil)
+)
+
 // Client S3 storage
 type Client struct {
 	*s3.S3
@@ -43,6 +60,10 @@ type Config struct {
 	S3Endpoint       string
 	S3ForcePathStyle bool
 	CacheControl     string
+	// PartSize size of each part of multipart uploads, s3manager.DefaultUploadPartSize (5MB) if not set
+	PartSize int64
+	// Concurrency number of parts uploaded in parallel, s3manager.DefaultUploadConcurrency (5) if not set
+	Concurrency int
 
 	Session *session.Session
 
@@ -115,9 +136,73 @@ func New(config *Config) *Client {
 	return client
 }
 
+func init() {
+	oss.Register("s3", func(u *url.URL) (oss.StorageInterface, error) {
+		return NewFromURL(u)
+	})
+}
+
+// NewFromURL initialize S3 storage from URL like s3://bucket?region=us-east-1&acl=private, supported parameters are
+// region, acl, endpoint, s3_endpoint, s3_force_path_style, cache_control, role_arn, part_size and concurrency. Access key
+// could be given as s3://access_id:access_key@bucket, otherwise AWS default credentials are used
+func NewFromURL(u *url.URL) (*Client, error) {
+	var (
+		err    error
+		query  = u.Query()
+		config = &Config{
+			Bucket:       u.Host,
+			Region:       query.Get("region"),
+			ACL:          query.Get("acl"),
+			Endpoint:     query.Get("endpoint"),
+			S3Endpoint:   query.Get("s3_endpoint"),
+			CacheControl: query.Get("cache_control"),
+			RoleARN:      query.Get("role_arn"),
+			SessionToken: query.Get("session_token"),
+		}
+	)
+
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("s3: no bucket given in URL %v", u)
+	}
+
+	if u.User != nil {
+		config.AccessID = u.User.Username()
+		config.AccessKey, _ = u.User.Password()
+	}
+
+	if value := query.Get("s3_force_path_style"); value != "" {
+		if config.S3ForcePathStyle, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("s3: invalid s3_force_path_style %q: %v", value, err)
+		}
+	}
+
+	if value := query.Get("part_size"); value != "" {
+		if config.PartSize, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("s3: invalid part_size %q: %v", value, err)
+		}
+	}
+
+	if value := query.Get("concurrency"); value != "" {
+		if config.Concurrency, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("s3: invalid concurrency %q: %v", value, err)
+		}
+	}
+
+	client := New(config)
+	if client.S3 == nil {
+		return nil, fmt.Errorf("s3: invalid credentials in URL")
+	}
+	return client, nil
+}
+
 // Get receive file with given path
 func (client Client) Get(path string) (file *os.File, err error) {
-	readCloser, err := client.GetStream(path)
+	return client.GetCtx(context.Background(), path)
+}
+
+// GetCtx receive file with given path
+func (client Client) GetCtx(ctx context.Context, path string) (file *os.File, err error) {
+	readCloser, err := client.GetStreamCtx(ctx, path)
 
 	ext := filepath.Ext(path)
 	pattern := fmt.Sprintf("s3*%s", ext)
@@ -135,86 +220,356 @@ func (client Client) Get(path string) (file *os.File, err error) {
 
 // GetStream get file as stream
 func (client Client) GetStream(path string) (io.ReadCloser, error) {
+	return client.GetStreamCtx(context.Background(), path)
+}
+
+// GetStreamCtx get file as stream
+func (client Client) GetStreamCtx(ctx context.Context, path string) (io.ReadCloser, error) {
+	getResponse, err := client.S3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(client.Config.Bucket),
+		Key:    aws.String(client.ToRelativePath(path)),
+	})
+
+	if err != nil {
+		return nil, wrapError("get", path, err)
+	}
+	return verify(getResponse), nil
+}
+
+// verify verify body of a whole object with its ETag at EOF. ETag is the MD5 of objects uploaded in a single part
+// and not encrypted with KMS or customer keys, other objects, and bodies decompressed by the HTTP client, are not verified
+func verify(output *s3.GetObjectOutput) io.ReadCloser {
+	sum, err := hex.DecodeString(unquoteETag(output.ETag))
+	if err != nil || len(sum) != md5.Size || output.ContentLength == nil || output.SSECustomerAlgorithm != nil ||
+		aws.StringValue(output.ServerSideEncryption) == s3.ServerSideEncryptionAwsKms {
+		return output.Body
+	}
+	return oss.VerifyReader(output.Body, md5.New(), sum)
+}
+
+// GetRange get length bytes of object starting at offset with a ranged GetObject
+func (client Client) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
 	getResponse, err := client.S3.GetObject(&s3.GetObjectInput{
 		Bucket: aws.String(client.Config.Bucket),
 		Key:    aws.String(client.ToRelativePath(path)),
+		Range:  aws.String(oss.RangeHeader(offset, length)),
 	})
 
-	return getResponse.Body, err
+	if err != nil {
+		return nil, wrapError("get", path, err)
+	}
+	return getResponse.Body, nil
 }
 
 // Put store a reader into given path
 func (client Client) Put(urlPath string, reader io.Reader) (*oss.Object, error) {
-	if seeker, ok := reader.(io.ReadSeeker); ok {
-		seeker.Seek(0, 0)
+	return client.PutCtx(context.Background(), urlPath, reader)
+}
+
+// PutCtx store a reader into given path
+func (client Client) PutCtx(ctx context.Context, urlPath string, reader io.Reader) (*oss.Object, error) {
+	return client.putCtx(ctx, urlPath, reader, &oss.PutOptions{})
+}
+
+// PutWithOptions store a reader into given path with options, all options are supported
+func (client Client) PutWithOptions(urlPath string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	if options == nil {
+		options = &oss.PutOptions{}
 	}
+	return client.putCtx(context.Background(), urlPath, reader, options)
+}
 
+func (client Client) putCtx(ctx context.Context, urlPath string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
 	urlPath = client.ToRelativePath(urlPath)
-	buffer, err := ioutil.ReadAll(reader)
+	if reader == nil {
+		reader = bytes.NewReader(nil)
+	}
+
+	// only the first 512 bytes are read to detect content type, the rest is streamed to the uploader
+	var (
+		size     int64
+		body     io.Reader
+		checksum *oss.Checksum
+		counter  *oss.ChecksumReader
+		sniff    = make([]byte, 512)
+	)
+
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		seeker.Seek(0, io.SeekStart)
+	}
+
+	n, err := io.ReadFull(reader, sniff)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, wrapError("put", urlPath, err)
+	}
+	sniff = sniff[:n]
 
-	fileType := mime.TypeByExtension(path.Ext(urlPath))
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		// the uploader reads parts of seekable readers without buffering them, so checksums are computed beforehand
+		if size, err = seeker.Seek(0, io.SeekEnd); err == nil {
+			checksum, err = oss.SeekerChecksum(seeker)
+		}
+		if err != nil {
+			return nil, wrapError("put", urlPath, err)
+		}
+		body = seeker
+	} else {
+		counter = oss.NewChecksumReader(io.MultiReader(bytes.NewReader(sniff), reader))
+		body = counter
+	}
+
+	fileType := options.ContentType
+	if fileType == "" {
+		fileType = mime.TypeByExtension(path.Ext(urlPath))
+	}
 	if fileType == "" {
-		fileType = http.DetectContentType(buffer)
+		fileType = http.DetectContentType(sniff)
 	}
 
-	params := &s3.PutObjectInput{
-		Bucket:        aws.String(client.Config.Bucket), // required
-		Key:           aws.String(urlPath),              // required
-		ACL:           aws.String(client.Config.ACL),
-		Body:          bytes.NewReader(buffer),
-		ContentLength: aws.Int64(int64(len(buffer))),
-		ContentType:   aws.String(fileType),
+	acl := options.ACL
+	if acl == "" {
+		acl = client.Config.ACL
 	}
-	if client.Config.CacheControl != "" {
+
+	params := &s3manager.UploadInput{
+		Bucket:      aws.String(client.Config.Bucket), // required
+		Key:         aws.String(urlPath),              // required
+		ACL:         aws.String(acl),
+		Body:        body,
+		ContentType: aws.String(fileType),
+	}
+	// Content-MD5 of single part uploads, the SDK sends Content-MD5 of each part of multipart uploads
+	if checksum != nil {
+		params.ContentMD5 = aws.String(checksum.ContentMD5())
+	}
+	if options.CacheControl != "" {
+		params.CacheControl = aws.String(options.CacheControl)
+	} else if client.Config.CacheControl != "" {
 		params.CacheControl = aws.String(client.Config.CacheControl)
 	}
+	if options.ContentDisposition != "" {
+		params.ContentDisposition = aws.String(options.ContentDisposition)
+	}
+	if options.ContentEncoding != "" {
+		params.ContentEncoding = aws.String(options.ContentEncoding)
+	}
+	if len(options.Metadata) > 0 {
+		params.Metadata = aws.StringMap(options.Metadata)
+	}
+
+	// parts of failed uploads are aborted by the uploader, as LeavePartsOnError is false
+	uploader := s3manager.NewUploaderWithClient(client.S3, func(uploader *s3manager.Uploader) {
+		uploader.PartSize = client.Config.PartSize
+		uploader.Concurrency = client.Config.Concurrency
+	})
+	uploadResponse, err := uploader.UploadWithContext(ctx, params)
 
-	_, err = client.S3.PutObject(params)
+	if counter != nil {
+		size = counter.Size()
+		checksum = counter.Checksum()
+	}
 
 	now := time.Now()
-	return &oss.Object{
+	object := &oss.Object{
 		Path:             urlPath,
 		Name:             filepath.Base(urlPath),
 		LastModified:     &now,
+		Size:             size,
+		ContentType:      fileType,
+		Metadata:         options.Metadata,
 		StorageInterface: client,
-	}, err
+	}
+	if err == nil {
+		object.Checksum = checksum
+		object.ETag = unquoteETag(uploadResponse.ETag)
+	}
+	return object, wrapError("put", urlPath, err)
+}
+
+// Stat get object's metadata with HeadObject
+func (client Client) Stat(path string) (*oss.Object, error) {
+	key := client.ToRelativePath(path)
+	headResponse, err := client.S3.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(client.Config.Bucket),
+		Key:    aws.String(key),
+	})
+
+	if err != nil {
+		return nil, wrapError("stat", path, err)
+	}
+
+	storageClass := aws.StringValue(headResponse.StorageClass)
+	if storageClass == "" {
+		storageClass = s3.StorageClassStandard
+	}
+
+	return &oss.Object{
+		Path:             key,
+		Name:             filepath.Base(key),
+		LastModified:     headResponse.LastModified,
+		Size:             aws.Int64Value(headResponse.ContentLength),
+		ETag:             unquoteETag(headResponse.ETag),
+		ContentType:      aws.StringValue(headResponse.ContentType),
+		StorageClass:     storageClass,
+		Metadata:         aws.StringValueMap(headResponse.Metadata),
+		StorageInterface: client,
+	}, nil
 }
 
 // Delete delete file
 func (client Client) Delete(path string) error {
-	_, err := client.S3.DeleteObject(&s3.DeleteObjectInput{
+	return client.DeleteCtx(context.Background(), path)
+}
+
+// DeleteCtx delete file
+func (client Client) DeleteCtx(ctx context.Context, path string) error {
+	_, err := client.S3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(client.Config.Bucket),
 		Key:    aws.String(client.ToRelativePath(path)),
 	})
-	return err
+	return wrapError("delete", path, err)
+}
+
+// DeleteMany delete objects with DeleteObjects, 1000 objects per request
+func (client Client) DeleteMany(paths []string) map[string]error {
+	errs := map[string]error{}
+
+	for start := 0; start < len(paths); start += 1000 {
+		var (
+			end     = start + 1000
+			keys    = map[string]string{}
+			objects []*s3.ObjectIdentifier
+		)
+		if end > len(paths) {
+			end = len(paths)
+		}
+
+		for _, path := range paths[start:end] {
+			key := strings.TrimPrefix(client.ToRelativePath(path), "/")
+			keys[key] = path
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(key)})
+		}
+
+		deleteResponse, err := client.S3.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: aws.String(client.Config.Bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+
+		if err != nil {
+			for _, path := range paths[start:end] {
+				errs[path] = wrapError("delete", path, err)
+			}
+			continue
+		}
+
+		for _, deleteError := range deleteResponse.Errors {
+			path := keys[aws.StringValue(deleteError.Key)]
+			errs[path] = wrapError("delete", path, awserr.New(aws.StringValue(deleteError.Code), aws.StringValue(deleteError.Message), nil))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Copy copy object from src to dst with CopyObject
+func (client Client) Copy(src, dst string) error {
+	copySource := url.URL{Path: client.Config.Bucket + client.ToRelativePath(src)}
+	_, err := client.S3.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(client.Config.Bucket),
+		Key:        aws.String(client.ToRelativePath(dst)),
+		CopySource: aws.String(copySource.EscapedPath()),
+		ACL:        aws.String(client.Config.ACL),
+	})
+	return wrapError("copy", src, err)
+}
+
+// Move move object from src to dst, S3 has no native move so it copies the object and deletes src
+func (client Client) Move(src, dst string) error {
+	if err := client.Copy(src, dst); err != nil {
+		return err
+	}
+	return client.Delete(src)
 }
 
 // List list all objects under current path
 func (client Client) List(path string) ([]*oss.Object, error) {
-	var objects []*oss.Object
-	var prefix string
+	return client.ListCtx(context.Background(), path)
+}
 
-	if path != "" {
-		prefix = strings.Trim(path, "/") + "/"
+// ListCtx list all objects under current path
+func (client Client) ListCtx(ctx context.Context, path string) ([]*oss.Object, error) {
+	var (
+		objects []*oss.Object
+		options oss.ListOptions
+	)
+
+	if prefix := strings.Trim(path, "/"); prefix != "" {
+		options.Prefix = prefix + "/"
 	}
 
-	listObjectsResponse, err := client.S3.ListObjectsV2(&s3.ListObjectsV2Input{
-		Bucket: aws.String(client.Config.Bucket),
-		Prefix: aws.String(prefix),
-	})
+	for {
+		result, err := client.listPage(ctx, options)
+		if err != nil {
+			return nil, wrapError("list", path, err)
+		}
+		objects = append(objects, result.Objects...)
 
-	if err == nil {
-		for _, content := range listObjectsResponse.Contents {
-			objects = append(objects, &oss.Object{
-				Path:             client.ToRelativePath(*content.Key),
-				Name:             filepath.Base(*content.Key),
-				LastModified:     content.LastModified,
-				StorageInterface: client,
-			})
+		if result.NextContinuationToken == "" {
+			return objects, nil
 		}
+		options.ContinuationToken = result.NextContinuationToken
 	}
+}
 
-	return objects, err
+// ListPage list a page of objects with ListObjectsV2
+func (client Client) ListPage(options oss.ListOptions) (*oss.ListResult, error) {
+	result, err := client.listPage(context.Background(), options)
+	return result, wrapError("list", options.Prefix, err)
+}
+
+func (client Client) listPage(ctx context.Context, options oss.ListOptions) (*oss.ListResult, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(client.Config.Bucket),
+		Prefix: aws.String(strings.TrimPrefix(options.Prefix, "/")),
+	}
+	if options.Delimiter != "" {
+		input.Delimiter = aws.String(options.Delimiter)
+	}
+	if options.StartAfter != "" {
+		input.StartAfter = aws.String(strings.TrimPrefix(options.StartAfter, "/"))
+	}
+	if options.ContinuationToken != "" {
+		input.ContinuationToken = aws.String(options.ContinuationToken)
+	}
+	if options.MaxKeys > 0 {
+		input.MaxKeys = aws.Int64(int64(options.MaxKeys))
+	}
+
+	listObjectsResponse, err := client.S3.ListObjectsV2WithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &oss.ListResult{NextContinuationToken: aws.StringValue(listObjectsResponse.NextContinuationToken)}
+	for _, content := range listObjectsResponse.Contents {
+		result.Objects = append(result.Objects, &oss.Object{
+			Path:             client.ToRelativePath(*content.Key),
+			Name:             filepath.Base(*content.Key),
+			LastModified:     content.LastModified,
+			Size:             aws.Int64Value(content.Size),
+			ETag:             unquoteETag(content.ETag),
+			StorageClass:     aws.StringValue(content.StorageClass),
+			StorageInterface: client,
+		})
+	}
+	for _, commonPrefix := range listObjectsResponse.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, aws.StringValue(commonPrefix.Prefix))
+	}
+
+	return result, nil
 }
 
 // GetEndpoint get endpoint, FileSystem's endpoint is /
@@ -231,6 +586,20 @@ func (client Client) GetEndpoint() string {
 	return client.Config.Bucket + "." + endpoint
 }
 
+// wrapError wrap S3 error into oss.Error with the HTTP status code of the response
+func wrapError(op, path string, err error) error {
+	var statusCode int
+	if requestFailure, ok := err.(awserr.RequestFailure); ok {
+		statusCode = requestFailure.StatusCode()
+	}
+	return oss.NewError(op, path, statusCode, err)
+}
+
+// unquoteETag strip the quotes S3 puts around ETags
+func unquoteETag(etag *string) string {
+	return strings.Trim(aws.StringValue(etag), `"`)
+}
+
 var urlRegexp = regexp.MustCompile(`(https?:)?//((\w+).)+(\w+)/`)
 
 // ToRelativePath process path to relative path
@@ -252,14 +621,21 @@ func (client Client) ToRelativePath(urlPath string) string {
 
 // GetURL get public accessible URL
 func (client Client) GetURL(path string) (url string, err error) {
+	return client.GetURLCtx(context.Background(), path)
+}
+
+// GetURLCtx get public accessible URL
+func (client Client) GetURLCtx(ctx context.Context, path string) (url string, err error) {
 	if client.Endpoint == "" {
 		if client.Config.ACL == s3.BucketCannedACLPrivate || client.Config.ACL == s3.BucketCannedACLAuthenticatedRead {
 			getResponse, _ := client.S3.GetObjectRequest(&s3.GetObjectInput{
 				Bucket: aws.String(client.Config.Bucket),
 				Key:    aws.String(client.ToRelativePath(path)),
 			})
+			getResponse.SetContext(ctx)
 
-			return getResponse.Presign(1 * time.Hour)
+			url, err = getResponse.Presign(1 * time.Hour)
+			return url, wrapError("url", path, err)
 		}
 	}
 
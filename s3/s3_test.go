@@ -1,11 +1,22 @@
 package s3_test
 
 import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
 	awss3 "github.com/aws/aws-sdk-go/service/s3"
 	"github.com/jinzhu/configor"
+	"github.com/qor/oss"
 	"github.com/qor/oss/s3"
 	"github.com/qor/oss/tests"
 )
@@ -76,3 +87,162 @@ func TestToRelativePathWithS3ForcePathStyle(t *testing.T) {
 		}
 	}
 }
+
+// fakeMultipartServer records multipart upload requests, failPart makes the part fail
+func fakeMultipartServer(failPart string) (*httptest.Server, *sync.Map) {
+	requests := &sync.Map{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		body, _ := ioutil.ReadAll(req.Body)
+		_, initiate := query["uploads"]
+
+		switch {
+		case req.Method == "POST" && initiate:
+			requests.Store("create", req.Header.Get("Content-Type"))
+			fmt.Fprint(w, `<InitiateMultipartUploadResult><UploadId>upload-id</UploadId></InitiateMultipartUploadResult>`)
+		case req.Method == "PUT" && query.Get("partNumber") != "":
+			if query.Get("partNumber") == failPart {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			requests.Store("part"+query.Get("partNumber"), len(body))
+			w.Header().Set("ETag", `"part-etag"`)
+		case req.Method == "POST" && query.Get("uploadId") != "":
+			requests.Store("complete", true)
+			fmt.Fprint(w, `<CompleteMultipartUploadResult><ETag>"object-etag"</ETag></CompleteMultipartUploadResult>`)
+		case req.Method == "DELETE" && query.Get("uploadId") != "":
+			requests.Store("abort", true)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})), requests
+}
+
+func TestPutMultipart(t *testing.T) {
+	server, requests := fakeMultipartServer("")
+	defer server.Close()
+
+	client := s3.New(&s3.Config{AccessID: "access_id", AccessKey: "access_key", Region: "us-east-1", Bucket: "bucket", S3Endpoint: server.URL, S3ForcePathStyle: true, Concurrency: 2})
+
+	// a reader that isn't seekable is streamed in 5MB parts
+	content := bytes.Repeat([]byte("<html>"), 2*1024*1024)
+	object, err := client.Put("/sample", io.MultiReader(bytes.NewReader(content)))
+	if err != nil {
+		t.Fatalf("No error should happen when put multipart object, but got %v", err)
+	}
+
+	if object.Size != int64(len(content)) {
+		t.Errorf("Object's size should be %v, but got %v", len(content), object.Size)
+	}
+	if object.ETag != "object-etag" {
+		t.Errorf("Object's ETag should be object-etag, but got %v", object.ETag)
+	}
+	if contentType, _ := requests.Load("create"); contentType != "text/html; charset=utf-8" {
+		t.Errorf("Content type should be sniffed from the first bytes, but got %v", contentType)
+	}
+	for _, part := range []string{"part1", "part2", "part3"} {
+		if _, ok := requests.Load(part); !ok {
+			t.Errorf("%v should be uploaded", part)
+		}
+	}
+}
+
+func TestPutMultipartAbort(t *testing.T) {
+	server, requests := fakeMultipartServer("2")
+	defer server.Close()
+
+	client := s3.New(&s3.Config{AccessID: "access_id", AccessKey: "access_key", Region: "us-east-1", Bucket: "bucket", S3Endpoint: server.URL, S3ForcePathStyle: true})
+	client.S3.Client.Config.MaxRetries = aws.Int(0)
+
+	content := bytes.Repeat([]byte("sample"), 2*1024*1024)
+	if _, err := client.Put("/sample.txt", io.MultiReader(bytes.NewReader(content))); err == nil {
+		t.Errorf("There should be an error when upload part failed")
+	}
+
+	if _, ok := requests.Load("abort"); !ok {
+		t.Errorf("Multipart upload should be aborted when upload part failed")
+	}
+	if _, ok := requests.Load("complete"); ok {
+		t.Errorf("Multipart upload should not be completed when upload part failed")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	storage, err := oss.Open("s3://access_id:access_key@mybucket?region=us-east-1&acl=private&s3_force_path_style=true&part_size=10485760&concurrency=3")
+	if err != nil {
+		t.Fatalf("No error should happen when open s3 URL, but got %v", err)
+	}
+
+	config := storage.(*s3.Client).Config
+	expected := s3.Config{AccessID: "access_id", AccessKey: "access_key", Region: "us-east-1", Bucket: "mybucket", ACL: "private", S3ForcePathStyle: true, PartSize: 10485760, Concurrency: 3}
+	if *config != expected {
+		t.Errorf("Config should be %#v, but got %#v", expected, *config)
+	}
+
+	if _, err := oss.Open("s3://mybucket?part_size=large"); err == nil {
+		t.Errorf("There should be an error when open s3 URL with invalid part size")
+	}
+}
+
+// fakeObjectServer stores objects put to it, responding with MD5 ETags, corrupt flips a byte of objects got from it
+func fakeObjectServer(corrupt bool) (*httptest.Server, *sync.Map) {
+	objects := &sync.Map{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case "PUT":
+			body, _ := ioutil.ReadAll(req.Body)
+			sum := md5.Sum(body)
+			if req.Header.Get("Content-MD5") != base64.StdEncoding.EncodeToString(sum[:]) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			objects.Store(req.URL.Path, body)
+			w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sum))
+		case "GET":
+			value, ok := objects.Load(req.URL.Path)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			body := append([]byte{}, value.([]byte)...)
+			w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(body)))
+			if corrupt {
+				body[0] ^= 1
+			}
+			w.Write(body)
+		}
+	})), objects
+}
+
+func TestChecksum(t *testing.T) {
+	content := []byte("sample content")
+	sum := md5.Sum(content)
+
+	for _, corrupt := range []bool{false, true} {
+		server, _ := fakeObjectServer(corrupt)
+		client := s3.New(&s3.Config{AccessID: "access_id", AccessKey: "access_key", Region: "us-east-1", Bucket: "bucket", S3Endpoint: server.URL, S3ForcePathStyle: true})
+
+		for _, reader := range []io.Reader{bytes.NewReader(content), io.MultiReader(bytes.NewReader(content))} {
+			object, err := client.Put("/sample.txt", reader)
+			if err != nil {
+				t.Fatalf("No error should happen when put with Content-MD5, but got %v", err)
+			}
+			if object.Checksum == nil || !bytes.Equal(object.Checksum.MD5, sum[:]) {
+				t.Errorf("Put object should have MD5 checksum %x, but got %+v", sum, object.Checksum)
+			}
+		}
+
+		stream, err := client.GetStream("/sample.txt")
+		if err != nil {
+			t.Fatalf("No error should happen when get object, but got %v", err)
+		}
+		_, err = ioutil.ReadAll(stream)
+		stream.Close()
+
+		if corrupt != errors.Is(err, oss.ErrChecksumMismatch) {
+			t.Errorf("Reading corrupted object should fail with oss.ErrChecksumMismatch, corrupted: %v, got %v", corrupt, err)
+		}
+		server.Close()
+	}
+}
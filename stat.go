@@ -0,0 +1,40 @@
+package oss
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+// Stater is implemented by storages that could read object's metadata without downloading its content
+type Stater interface {
+	// Stat get object's metadata, returns an error matching ErrNotExist if the object doesn't exist
+	Stat(path string) (*Object, error)
+}
+
+// Stat get object's metadata with storage's Stat, storages don't implement Stater fall back to
+// opening the object as stream, so only Path and Name are available
+func Stat(storage StorageInterface, path string) (*Object, error) {
+	if stater, ok := storage.(Stater); ok {
+		return stater.Stat(path)
+	}
+
+	stream, err := storage.GetStream(path)
+	if err != nil {
+		return nil, err
+	}
+	stream.Close()
+
+	return &Object{Path: path, Name: filepath.Base(path), StorageInterface: storage}, nil
+}
+
+// Exists check object exists or not
+func Exists(storage StorageInterface, path string) (bool, error) {
+	_, err := Stat(storage, path)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, ErrNotExist) {
+		return false, nil
+	}
+	return false, err
+}
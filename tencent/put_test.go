@@ -0,0 +1,44 @@
+package tencent
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// rewriteTransport send requests to server instead of COS
+type rewriteTransport struct {
+	server *url.URL
+}
+
+func (transport rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = transport.server.Scheme, transport.server.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestPutNilBody(t *testing.T) {
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received, _ = ioutil.ReadAll(req.Body)
+		if req.Header.Get("Content-MD5") != "1B2M2Y8AsgTpgAmY7PhCfg==" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := New(&Config{Bucket: "bucket", Region: "region"})
+	client.Client = &http.Client{Transport: rewriteTransport{server: serverURL}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	object, err := client.PutCtx(ctx, "/empty.txt", nil)
+	if err != nil || object.Size != 0 || len(received) != 0 {
+		t.Errorf("Nil body should be put as empty object, but got %#v, %v", object, err)
+	}
+}
@@ -1,6 +1,10 @@
 package tests
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -21,6 +25,7 @@ func TestAll(storage oss.StorageInterface, t *testing.T) {
 	fileName2 := "/" + filepath.Join(randomPath, "sample2", "sample.txt")
 	exceptObjects := 2
 	sampleFile, _ := filepath.Abs("../tests/sample.txt")
+	sampleInfo, _ := os.Stat(sampleFile)
 
 	// Put file
 	if file, err := os.Open(sampleFile); err == nil {
@@ -28,6 +33,12 @@ func TestAll(storage oss.StorageInterface, t *testing.T) {
 			t.Errorf("No error should happen when save sample file, but got %v", err)
 		} else if object.Path == "" || object.StorageInterface == nil {
 			t.Errorf("returned object should necessary information")
+		} else if object.Size != sampleInfo.Size() {
+			t.Errorf("returned object's size should be %v, but got %v", sampleInfo.Size(), object.Size)
+		} else if sample, err := ioutil.ReadFile(sampleFile); err == nil && object.Checksum != nil {
+			if sum := md5.Sum(sample); !bytes.Equal(object.Checksum.MD5, sum[:]) {
+				t.Errorf("returned object's MD5 checksum should be %x, but got %x", sum, object.Checksum.MD5)
+			}
 		}
 	} else {
 		t.Errorf("No error should happen when opem sample file, but got %v", err)
@@ -43,6 +54,72 @@ func TestAll(storage oss.StorageInterface, t *testing.T) {
 		t.Errorf("No error should happen when opem sample file, but got %v", err)
 	}
 
+	// Put file with options
+	fileName3 := "/" + filepath.Join(randomPath, "sample3.txt")
+	if file, err := os.Open(sampleFile); err == nil {
+		options := &oss.PutOptions{ContentType: "text/plain", CacheControl: "max-age=60", Metadata: map[string]string{"author": "qor"}}
+		if object, err := oss.PutWithOptions(storage, fileName3, file, options); err != nil {
+			t.Errorf("No error should happen when save sample file with options, but got %v", err)
+		} else if object.Size != sampleInfo.Size() {
+			t.Errorf("returned object's size should be %v, but got %v", sampleInfo.Size(), object.Size)
+		} else if object, err := oss.Stat(storage, fileName3); err != nil {
+			t.Errorf("No error should happen when stat sample file saved with options, but got %v", err)
+		} else if object.Metadata != nil && object.Metadata["author"] != "qor" {
+			t.Errorf("Stored metadata should be %v, but got %v", options.Metadata, object.Metadata)
+		}
+		file.Close()
+
+		if err := storage.Delete(fileName3); err != nil {
+			t.Errorf("No error should happen when delete sample file saved with options, but got %v", err)
+		}
+	}
+
+	// Copy and move
+	copiedFileName := "/" + filepath.Join(randomPath, "copied.txt")
+	movedFileName := "/" + filepath.Join(randomPath, "moved.txt")
+	if err := oss.Copy(storage, fileName, copiedFileName); err != nil {
+		t.Errorf("No error should happen when copy sample file, but got %v", err)
+	} else if err := oss.Move(storage, copiedFileName, movedFileName); err != nil {
+		t.Errorf("No error should happen when move copied file, but got %v", err)
+	} else {
+		if stream, err := storage.GetStream(movedFileName); err != nil {
+			t.Errorf("No error should happen when get moved file, but got %v", err)
+		} else {
+			if buffer, err := ioutil.ReadAll(stream); err != nil || int64(len(buffer)) != sampleInfo.Size() {
+				t.Errorf("Moved file should have same content as sample file, but got %v bytes, %v", len(buffer), err)
+			}
+			stream.Close()
+		}
+
+		if _, err := storage.GetStream(copiedFileName); !errors.Is(err, oss.ErrNotExist) {
+			t.Errorf("Copied file should not exist after moved, but got %v", err)
+		}
+
+		if err := storage.Delete(movedFileName); err != nil {
+			t.Errorf("No error should happen when delete moved file, but got %v", err)
+		}
+	}
+
+	// Put file with cancelled context
+	if contextStorage, ok := storage.(oss.ContextStorage); ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if file, err := os.Open(sampleFile); err == nil {
+			if _, err := contextStorage.PutCtx(ctx, "/"+filepath.Join(randomPath, "cancelled.txt"), file); err == nil {
+				t.Errorf("There should be an error when save sample file with cancelled context")
+			}
+			file.Close()
+		}
+	}
+
+	// Stat
+	if object, err := oss.Stat(storage, fileName); err != nil {
+		t.Errorf("No error should happen when stat sample file, but got %v", err)
+	} else if _, ok := storage.(oss.Stater); ok && object.Size != sampleInfo.Size() {
+		t.Errorf("Stat object's size should be %v, but got %v", sampleInfo.Size(), object.Size)
+	}
+
 	// Get file
 	if file, err := storage.Get(fileName); err != nil {
 		t.Errorf("No error should happen when get sample file, but got %v", err)
@@ -82,7 +159,35 @@ func TestAll(storage oss.StorageInterface, t *testing.T) {
 		}
 	}
 
-	// List
+	// Get range
+	if sample, err := ioutil.ReadFile(sampleFile); err == nil && len(sample) > 6 {
+		if stream, err := oss.GetRange(storage, fileName, 2, 4); err != nil {
+			t.Errorf("No error should happen when get range of sample file, but got %v", err)
+		} else {
+			if buffer, err := ioutil.ReadAll(stream); err != nil || string(buffer) != string(sample[2:6]) {
+				t.Errorf("Range of sample file should be %q, but got %q, %v", sample[2:6], buffer, err)
+			}
+			stream.Close()
+		}
+
+		if reader, err := oss.OpenReaderAt(storage, fileName); err != nil {
+			t.Errorf("No error should happen when open sample file for random access, but got %v", err)
+		} else {
+			buffer := make([]byte, 3)
+			if n, err := reader.ReadAt(buffer, int64(len(sample)-3)); err != nil || string(buffer[:n]) != string(sample[len(sample)-3:]) {
+				t.Errorf("ReadAt should return %q, but got %q, %v", sample[len(sample)-3:], buffer[:n], err)
+			}
+			reader.Close()
+		}
+	}
+
+	// List, objects of sibling directory sharing the prefix shouldn't be listed
+	siblingName := "/" + filepath.Join(randomPath+"sibling", "sample.txt")
+	if _, err := storage.Put(siblingName, strings.NewReader("sibling")); err != nil {
+		t.Errorf("No error should happen when save sibling file, but got %v", err)
+	}
+	defer storage.Delete(siblingName)
+
 	if objects, err := storage.List(randomPath); err != nil {
 		t.Errorf("No error should happen when list objects, but got %v", err)
 	} else if len(objects) != exceptObjects {
@@ -92,6 +197,10 @@ func TestAll(storage oss.StorageInterface, t *testing.T) {
 		for _, object := range objects {
 			if object.Path == fileName {
 				found1 = true
+
+				if object.Size != sampleInfo.Size() {
+					t.Errorf("Listed object's size should be %v, but got %v", sampleInfo.Size(), object.Size)
+				}
 			}
 
 			if object.Path == fileName2 {
@@ -108,6 +217,15 @@ func TestAll(storage oss.StorageInterface, t *testing.T) {
 		}
 	}
 
+	// List page with delimiter
+	if result, err := oss.ListPage(storage, oss.ListOptions{Prefix: randomPath + "/", Delimiter: "/"}); err != nil {
+		t.Errorf("No error should happen when list page, but got %v", err)
+	} else if len(result.Objects) != 1 || result.Objects[0].Path != fileName {
+		t.Errorf("Should found uploaded file %v in listed page, but got %v objects", fileName, len(result.Objects))
+	} else if len(result.CommonPrefixes) != 1 || result.CommonPrefixes[0] != randomPath+"/sample2/" {
+		t.Errorf("Should found common prefix %v, but got %v", randomPath+"/sample2/", result.CommonPrefixes)
+	}
+
 	// Delete
 	if err := storage.Delete(fileName); err != nil {
 		t.Errorf("No error should happen when delete sample file, but got %v", err)
@@ -116,10 +234,30 @@ func TestAll(storage oss.StorageInterface, t *testing.T) {
 	// Get file after delete
 	if _, err := storage.Get(fileName); err == nil {
 		t.Errorf("There should be an error when get deleted sample file")
+	} else if !errors.Is(err, oss.ErrNotExist) {
+		t.Errorf("Error of getting deleted sample file should match oss.ErrNotExist, but got %v", err)
+	}
+
+	// Exists after delete
+	if _, ok := storage.(oss.Stater); ok {
+		if exists, err := oss.Exists(storage, fileName); err != nil || exists {
+			t.Errorf("Deleted sample file should not exist, but got %v, %v", exists, err)
+		}
+
+		if exists, err := oss.Exists(storage, fileName2); err != nil || !exists {
+			t.Errorf("Sample file 2 should exist, but got %v, %v", exists, err)
+		}
 	}
 
 	// Get file after delete
 	if _, err := storage.Get(fileName2); err != nil {
 		t.Errorf("Sample file 2 should no been deleted")
 	}
+
+	// Delete prefix
+	if err := oss.DeletePrefix(storage, randomPath+"/"); err != nil {
+		t.Errorf("No error should happen when delete prefix, but got %v", err)
+	} else if objects, err := storage.List(randomPath); err != nil || len(objects) != 0 {
+		t.Errorf("All objects under prefix should be deleted, but got %v objects, %v", len(objects), err)
+	}
 }